@@ -0,0 +1,232 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"httpServer/validation"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxBindBodySize is the size of the bodies read by Bind, the same as the memory of the multipart forms.
+const maxBindBodySize = 32 << 20
+
+// Bind returns a T populated from r by the same struct tags as the OpenAPI spec:
+// `path`, `query`, `header` and `formData` fields are parsed from their values, the `json` fields are decoded from a JSON body,
+// and the fields missing from the request get their `default` tag. Repeated parameters fill slice fields.
+// The parameters which can't be parsed, the missing ones tagged `required:"true"` and the ones breaking their `validate` tag
+// (see validation.ValidateStruct) are reported by name. The bodies larger than maxBindBodySize and the JSON bodies
+// with data after their value are rejected.
+func Bind[T any](r *http.Request) (*T, *InvalidArgumentBadRequestResponse) {
+	target := new(T)
+	value := reflect.ValueOf(target).Elem()
+	errs := make(map[string][]*validation.ValidateError)
+	hasBody := false
+	err := walkBindFields(value, func(field reflect.StructField, fieldValue reflect.Value) error {
+		if raw, ok := field.Tag.Lookup("default"); ok {
+			err := parseBindValue(fieldValue, raw)
+			if err != nil {
+				return errors.New("default value of " + field.Name + ": " + err.Error())
+			}
+		}
+		if _, ok := field.Tag.Lookup("json"); ok {
+			hasBody = true
+		}
+		return nil
+	})
+	if err != nil {
+		// a default tag which doesn't parse as its field is a bug of T, every request would fail the same way
+		panic(err)
+	}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxBindBodySize)
+	}
+	hasForm := hasFormFields(value)
+	if hasForm {
+		// ParseMultipartForm falls back to ParseForm for the url-encoded bodies
+		err = r.ParseMultipartForm(maxBindBodySize)
+		if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			errs["body"] = []*validation.ValidateError{{Reason: "Invalid form: " + bodyErrorReason(err)}}
+		}
+	}
+	_ = walkBindFields(value, func(field reflect.StructField, fieldValue reflect.Value) error {
+		name, values, ok := bindSource(r, field)
+		if !ok {
+			return nil
+		}
+		if len(values) == 0 {
+			if field.Tag.Get("required") == "true" {
+				errs[name] = []*validation.ValidateError{{Reason: "Value is required"}}
+			}
+			return nil
+		}
+		if fieldValue.Kind() == reflect.Slice {
+			fieldValue.Set(reflect.MakeSlice(fieldValue.Type(), 0, len(values)))
+		} else {
+			values = values[:1]
+		}
+		for _, raw := range values {
+			err := parseBindValue(fieldValue, raw)
+			if err != nil {
+				errs[name] = append(errs[name], &validation.ValidateError{Reason: err.Error()})
+			}
+		}
+		return nil
+	})
+	// the body of a struct without formData fields is JSON whatever its Content-Type, as the handlers always did
+	if hasBody && !(hasForm && isFormContent(r)) {
+		decoder := json.NewDecoder(r.Body)
+		err = decoder.Decode(target)
+		if err == nil {
+			// the body is a single JSON value, a second one or garbage after it is a malformed request
+			if _, err = decoder.Token(); errors.Is(err, io.EOF) {
+				err = nil
+			} else if err == nil {
+				err = errors.New("unexpected data after the JSON value")
+			}
+		} else if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		if err != nil {
+			var typeError *json.UnmarshalTypeError
+			if errors.As(err, &typeError) && typeError.Field != "" {
+				errs[typeError.Field] = append(errs[typeError.Field], &validation.ValidateError{Reason: "Value must be " + typeError.Type.String()})
+			} else {
+				errs["body"] = append(errs["body"], &validation.ValidateError{Reason: "Invalid JSON: " + bodyErrorReason(err)})
+			}
+		}
+	}
+	_, invalid := validation.ValidateStruct(target, validation.DefaultValidateOptions)
+	for name, reasons := range invalid {
+		if _, ok := errs[name]; !ok {
+			// a value which couldn't be parsed is only reported once
+			errs[name] = reasons
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &InvalidArgumentBadRequestResponse{Errors: errs}
+	}
+	return target, nil
+}
+
+// bodyErrorReason describes the error reading the body, the bodies cut by maxBindBodySize get their limit.
+func bodyErrorReason(err error) string {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return "the body is larger than " + strconv.FormatInt(maxBytesError.Limit, 10) + " bytes"
+	}
+	return err.Error()
+}
+
+// bindSource returns the parameter name of field and its values in r, false when field is not a parameter.
+func bindSource(r *http.Request, field reflect.StructField) (string, []string, bool) {
+	if name, ok := field.Tag.Lookup("path"); ok {
+		if value := r.PathValue(name); value != "" {
+			return name, []string{value}, true
+		}
+		return name, nil, true
+	}
+	if name, ok := field.Tag.Lookup("query"); ok {
+		return name, r.URL.Query()[name], true
+	}
+	if name, ok := field.Tag.Lookup("header"); ok {
+		return name, r.Header.Values(name), true
+	}
+	if name, ok := field.Tag.Lookup("formData"); ok {
+		if r.MultipartForm != nil {
+			return name, r.MultipartForm.Value[name], true
+		}
+		return name, r.PostForm[name], true
+	}
+	return "", nil, false
+}
+
+func hasFormFields(value reflect.Value) bool {
+	found := false
+	_ = walkBindFields(value, func(field reflect.StructField, _ reflect.Value) error {
+		if _, ok := field.Tag.Lookup("formData"); ok {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+func isFormContent(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// walkBindFields calls fn for every exported field of the struct value, the embedded structs are walked recursively.
+func walkBindFields(value reflect.Value, fn func(field reflect.StructField, value reflect.Value) error) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			err := walkBindFields(value.Field(i), fn)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := fn(field, value.Field(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBindValue parses raw into value, a slice gets raw appended.
+func parseBindValue(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(raw), 10, value.Type().Bits())
+		if err != nil {
+			return errors.New("Value must be an integer")
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(strings.TrimSpace(raw), 10, value.Type().Bits())
+		if err != nil {
+			return errors.New("Value must be a positive integer")
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), value.Type().Bits())
+		if err != nil {
+			return errors.New("Value must be a number")
+		}
+		value.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return errors.New("Value must be true or false")
+		}
+		value.SetBool(parsed)
+	case reflect.Slice:
+		if raw == "" {
+			// an empty default is an empty list
+			value.Set(reflect.MakeSlice(value.Type(), 0, 0))
+			return nil
+		}
+		element := reflect.New(value.Type().Elem()).Elem()
+		err := parseBindValue(element, raw)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.Append(value, element))
+	default:
+		return errors.New("unsupported type " + value.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type bindParameters struct {
+	Id     int      `path:"id" required:"true"`
+	Page   int      `query:"page" default:"1" validate:"min=1"`
+	Tags   []string `query:"tag"`
+	Enable bool     `query:"enable" default:"true"`
+	Trace  string   `header:"X-Trace"`
+}
+
+type bindBody struct {
+	Name  string `json:"name" validate:"min=1"`
+	Count int    `json:"count" default:"3"`
+}
+
+type bindForm struct {
+	Name  string `formData:"name" required:"true"`
+	Count int    `formData:"count"`
+}
+
+type badDefault struct {
+	Count int `query:"count" default:"many"`
+}
+
+// bindRequest binds T from a request to target on a mux pattern, so the path values are set.
+func bindRequest[T any](t *testing.T, pattern string, request *http.Request) (*T, *InvalidArgumentBadRequestResponse) {
+	t.Helper()
+	var bound *T
+	var invalid *InvalidArgumentBadRequestResponse
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		bound, invalid = Bind[T](r)
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), request)
+	if bound == nil && invalid == nil {
+		t.Fatalf("%s doesn't match %s", request.URL, pattern)
+	}
+	return bound, invalid
+}
+
+func TestBindParameters(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/items/42?tag=a&tag=b&enable=false", nil)
+	request.Header.Set("X-Trace", "abc")
+	bound, invalid := bindRequest[bindParameters](t, "/items/{id}", request)
+	if invalid != nil {
+		t.Fatalf("got %v", invalid.Errors)
+	}
+	if bound.Id != 42 || bound.Page != 1 || len(bound.Tags) != 2 || bound.Tags[1] != "b" || bound.Enable || bound.Trace != "abc" {
+		t.Errorf("got %+v", bound)
+	}
+}
+
+func TestBindReportsTheInvalidParameters(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/items/x?page=0", nil)
+	_, invalid := bindRequest[bindParameters](t, "/items/{id}", request)
+	if invalid == nil {
+		t.Fatal("the request is bound")
+	}
+	if len(invalid.Errors["id"]) != 1 || invalid.Errors["id"][0].Reason != "Value must be an integer" {
+		t.Errorf("id: got %+v", invalid.Errors["id"])
+	}
+	if len(invalid.Errors["page"]) != 1 {
+		t.Errorf("page: got %+v", invalid.Errors["page"])
+	}
+	if len(invalid.Errors) != 2 {
+		t.Errorf("got %+v", invalid.Errors)
+	}
+}
+
+func TestBindJsonBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		error string
+	}{
+		{"valid", `{"name": "a"}`, ""},
+		{"trailing spaces", "{\"name\": \"a\"}\n  \n", ""},
+		{"empty body", ``, ""},
+		{"type error", `{"name": "a", "count": "3"}`, "count"},
+		{"syntax error", `{"name": `, "body"},
+		{"second value", `{"name": "a"} {"name": "b"}`, "body"},
+		{"trailing garbage", `{"name": "a"}garbage`, "body"},
+		{"trailing delimiter", `{"name": "a"}}`, "body"},
+		{"too large", `{"name": "` + strings.Repeat("a", maxBindBodySize) + `"}`, "body"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			bound, invalid := bindRequest[bindBody](t, "/", request)
+			if test.error == "" {
+				if invalid != nil {
+					t.Fatalf("got %+v", invalid.Errors)
+				}
+				if bound.Name != "a" && test.body != "" || bound.Count != 3 {
+					t.Errorf("got %+v", bound)
+				}
+				return
+			}
+			if invalid == nil || len(invalid.Errors[test.error]) == 0 {
+				t.Fatalf("no error for %s: %+v", test.error, invalid)
+			}
+			if test.name == "too large" && !strings.Contains(invalid.Errors["body"][0].Reason, "larger than") {
+				t.Errorf("got %s", invalid.Errors["body"][0].Reason)
+			}
+		})
+	}
+}
+
+func TestBindForms(t *testing.T) {
+	form := url.Values{"name": {"a"}, "count": {"2"}}
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	bound, invalid := bindRequest[bindForm](t, "/", request)
+	if invalid != nil || bound.Name != "a" || bound.Count != 2 {
+		t.Errorf("url-encoded form: got %+v, %+v", bound, invalid)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("count", "2")
+	_ = writer.Close()
+	request = httptest.NewRequest(http.MethodPost, "/", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	_, invalid = bindRequest[bindForm](t, "/", request)
+	if invalid == nil || len(invalid.Errors["name"]) != 1 || invalid.Errors["name"][0].Reason != "Value is required" {
+		t.Errorf("multipart form without name: got %+v", invalid)
+	}
+}
+
+func TestBindPanicsOnInvalidDefaults(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("the invalid default tag is ignored")
+		}
+	}()
+	Bind[badDefault](httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/swaggest/openapi-go"
 	"io"
 	"net/http"
 	"strconv"
@@ -15,7 +14,7 @@ import (
 
 type BrainFxxkRequest struct {
 	Code    string `json:"code" description:"The code of the request" example:"+[.+]"`
-	MemSize int    `json:"memSize" description:"The size of the memory in Byte" default:"8"`
+	MemSize int    `json:"memSize" description:"The size of the memory in Byte" default:"8" validate:"min=1,max=65536"`
 	Memory  string `json:"memory" description:"The default memory set in base64. Leave empty for full zero" default:""`
 	Stdin   string `json:"stdin" description:"The input to the program in base64"`
 }
@@ -38,36 +37,17 @@ const (
 	OpsClose Ops = ']'
 )
 
-func RouteBrainFxxkInterpretor(path string, builder *RouteBuilder) {
-	builder.Mux.HandleFunc(path, func(writer http.ResponseWriter, request *http.Request) {
-		if request.Method == http.MethodOptions {
-			writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-			writer.WriteHeader(http.StatusOK)
-			return
-		}
-		if request.Method != http.MethodPost {
-			writer.WriteHeader(http.StatusMethodNotAllowed)
-			writer.Write([]byte("Method not allowed"))
-			return
-		}
-
-		body, err := io.ReadAll(request.Body)
+func RouteBrainFxxkInterpretor(path string, builder *RouteBuilder) error {
+	handler := func(writer http.ResponseWriter, request *http.Request) {
 		defer func(Body io.ReadCloser) {
 			err := Body.Close()
 			if err != nil {
-				builder.ServiceProvider.Logger.Warning(err.Error())
+				builder.Logger(request, "api.BrainFxxkInterpretor").Warning(err.Error())
 			}
 		}(request.Body)
-		if err != nil {
-			writer.WriteHeader(http.StatusBadRequest)
-			writer.Write([]byte("Error reading request body"))
-			return
-		}
-		var req BrainFxxkRequest
-		err = json.Unmarshal(body, &req)
-		if err != nil {
-			writer.WriteHeader(http.StatusBadRequest)
-			writer.Write([]byte("Error unmarshalling request body"))
+		req, invalid := Bind[BrainFxxkRequest](request)
+		if invalid != nil {
+			invalid.Write(writer)
 			return
 		}
 
@@ -118,6 +98,19 @@ func RouteBrainFxxkInterpretor(path string, builder *RouteBuilder) {
 		if flusher, ok := writer.(http.Flusher); ok {
 			flusher.Flush()
 		}
+	}
+	return builder.Endpoint(Endpoint{
+		Method:             http.MethodPost,
+		Path:               path,
+		Handler:            handler,
+		Request:            new(BrainFxxkRequest),
+		RequestContentType: "application/json",
+		RequestDescription: "BrainFxxk request",
+		Responses: []Response{
+			{Status: http.StatusOK, Body: new(BrainFxxkResponse), ContentType: "application/json"},
+			{Status: http.StatusBadRequest, Body: new(string), ContentType: "text/plain", Description: "Invalid request"},
+			{Status: http.StatusBadRequest, Body: new(InvalidArgumentBadRequestResponse), ContentType: "application/json", Description: "Invalid request"},
+		},
 	})
 }
 
@@ -196,29 +189,3 @@ func interpret(code string, mem []byte, stdin []byte, ctx context.Context) (resu
 	}
 	return stdout, nil
 }
-
-func ConfigureBrainFxxkInterpretor(path string, builder *OpenApiBuilder) error {
-	context, err := builder.OpenApiReflector.NewOperationContext(http.MethodPost, path)
-	if err != nil {
-		return err
-	}
-	context.AddReqStructure(new(BrainFxxkRequest), func(cu *openapi.ContentUnit) {
-		cu.ContentType = "application/json"
-		cu.Description = "BrainFxxk request"
-	})
-	context.AddRespStructure(new(BrainFxxkResponse), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusOK
-		cu.ContentType = "application/json"
-	})
-	context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusBadRequest
-		cu.ContentType = "text/plain"
-		cu.Description = "Error"
-	})
-
-	err = builder.OpenApiReflector.AddOperation(context)
-	if err != nil {
-		return err
-	}
-	return nil
-}
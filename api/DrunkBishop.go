@@ -4,67 +4,49 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/swaggest/openapi-go"
 	"io"
 	"math/rand"
 	"mime/multipart"
 	"net/http"
-	"strconv"
 	"strings"
 )
 
 type DrunkBishopRequest struct {
-	Width  int    `query:"width" description:"Width of the image" example:"17" default:"17" required:"false"`
-	Height int    `query:"height" description:"Height of the image" example:"9" default:"9" required:"false"`
+	Width  int    `query:"width" description:"Width of the image" example:"17" default:"17" required:"false" validate:"min=1,max=256"`
+	Height int    `query:"height" description:"Height of the image" example:"9" default:"9" required:"false" validate:"min=1,max=256"`
 	Seed   string `query:"seed" description:"Random seed used to generate image when body is empty" default:"random" required:"false"`
-	Wrap   bool   `query:"wrap" description:"Wrap around the image with ASCII decorations" example:"true" default:"true" required:"false"`
+	Wrap   bool   `query:"wrap" description:"Wrap around the image with ASCII decorations" example:"true" default:"false" required:"false"`
 }
 
-func RouteDrunkBishop(path string, builder *RouteBuilder) {
-	builder.Mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-			w.WriteHeader(http.StatusOK)
+func RouteDrunkBishop(path string, builder *RouteBuilder) error {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		// the body is hashed whatever its size, so it gets the limit of Bind which comes after it
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBindBodySize))
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			_, _ = w.Write([]byte("Invalid request body: " + bodyErrorReason(err)))
 			return
 		}
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
-		data, err := io.ReadAll(r.Body)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("Invalid request body"))
 			return
 		}
+		req, invalid := Bind[DrunkBishopRequest](r)
+		if invalid != nil {
+			invalid.Write(w)
+			return
+		}
 		hash := [32]byte{}
-		var width = 17
-		var height = 9
 		var seed int64
-		var widthStr = r.URL.Query().Get("width")
-		var heightStr = r.URL.Query().Get("height")
-		var seedStr = r.URL.Query().Get("seed")
-		if widthStr != "" {
-			width, err = strconv.Atoi(widthStr)
-			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				_, _ = w.Write([]byte("Invalid width parameter"))
-				return
-			}
-		}
-		if heightStr != "" {
-			height, err = strconv.Atoi(heightStr)
-			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				_, _ = w.Write([]byte("Invalid height parameter"))
-				return
-			}
-		}
-		if seedStr != "" {
-			seed = stringToInt64(seedStr)
-		} else {
+		if req.Seed == "" || req.Seed == "random" {
 			seed = rand.Int63()
+		} else {
+			seed = stringToInt64(req.Seed)
 		}
 		if len(data) == 0 {
 			var src = rand.NewSource(seed)
@@ -75,10 +57,10 @@ func RouteDrunkBishop(path string, builder *RouteBuilder) {
 			hash = sha256.Sum256(data)
 		}
 
-		board := drunkBishop(hash, width, height)
+		board := drunkBishop(hash, req.Width, req.Height)
 		rendered := strings.TrimSuffix(renderDrunkBishop(board), "\n")
 		var result string
-		if r.URL.Query().Get("wrap") == "true" {
+		if req.Wrap {
 			var footer string
 			if len(data) == 0 {
 				seedData := make([]byte, 8)
@@ -93,44 +75,30 @@ func RouteDrunkBishop(path string, builder *RouteBuilder) {
 		}
 		w.Header().Set("Content-Type", "text/plain")
 		_, _ = w.Write([]byte(result))
-	})
-}
-
-func ConfigureDrunkBishop(path string, builder *OpenApiBuilder) error {
-	context, err := builder.OpenApiReflector.NewOperationContext(http.MethodPost, path)
-	if err != nil {
-		return err
 	}
-	context.SetDescription("Generate a Drunk Bishop ASCII image from a byte array.")
-	context.SetTags("image")
-	context.SetSummary("Drunk Bishop")
-
-	context.AddReqStructure(new(multipart.File), func(cu *openapi.ContentUnit) {
-		cu.Description = "File used to generate the Drunk Bishop image"
-		cu.ContentType = "application/octet-stream"
-	})
-	context.AddReqStructure(new(DrunkBishopRequest), func(cu *openapi.ContentUnit) {
-		cu.Description = "Drunk Bishop request"
+	return builder.Endpoint(Endpoint{
+		Method:      http.MethodPost,
+		Path:        path,
+		Handler:     handler,
+		Summary:     "Drunk Bishop",
+		Description: "Generate a Drunk Bishop ASCII image from a byte array.",
+		Tags:        []string{"image"},
+		Responses: []Response{
+			{Status: http.StatusOK, Body: new(string), ContentType: "text/plain", Description: "Drunk Bishop ASCII image", IsDefault: true},
+			{Status: http.StatusBadRequest, Body: new(string), ContentType: "text/plain", Description: "Invalid request"},
+			{Status: http.StatusBadRequest, Body: new(InvalidArgumentBadRequestResponse), ContentType: "application/json", Description: "Invalid request"},
+			{Status: http.StatusRequestEntityTooLarge, Body: new(string), ContentType: "text/plain", Description: "The body is larger than 32 MiB"},
+		},
+		Document: func(context openapi.OperationContext) {
+			context.AddReqStructure(new(multipart.File), func(cu *openapi.ContentUnit) {
+				cu.Description = "File used to generate the Drunk Bishop image"
+				cu.ContentType = "application/octet-stream"
+			})
+			context.AddReqStructure(new(DrunkBishopRequest), func(cu *openapi.ContentUnit) {
+				cu.Description = "Drunk Bishop request"
+			})
+		},
 	})
-
-	context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusOK
-		cu.ContentType = "text/plain"
-		cu.Description = "Drunk Bishop ASCII image"
-		cu.IsDefault = true
-	})
-	context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusBadRequest
-		cu.ContentType = "text/plain"
-		cu.Description = "Invalid request body"
-		cu.IsDefault = false
-	})
-
-	err = builder.OpenApiReflector.AddOperation(context)
-	if err != nil {
-		return err
-	}
-	return nil
 }
 
 var drunkBishopSymbols = []rune{
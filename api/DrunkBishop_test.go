@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newDrunkBishopBuilder(t *testing.T) *RouteBuilder {
+	t.Helper()
+	builder := NewRouteBuilder(nil, NewOpenApiBuilder())
+	if err := RouteDrunkBishop("/drunk_bishop", builder); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	return builder
+}
+
+func TestDrunkBishopHashesTheBody(t *testing.T) {
+	builder := newDrunkBishopBuilder(t)
+	images := make([]string, 2)
+	for i := range images {
+		request := httptest.NewRequest(http.MethodPost, "/drunk_bishop?width=5&height=3&wrap=false", strings.NewReader("key"))
+		recorder := httptest.NewRecorder()
+		builder.Handler().ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("got status %d, %s", recorder.Code, recorder.Body)
+		}
+		images[i] = recorder.Body.String()
+	}
+	if images[0] != images[1] || len(strings.Split(images[0], "\n")) != 3 {
+		t.Errorf("got %q and %q", images[0], images[1])
+	}
+}
+
+func TestDrunkBishopLimitsTheBody(t *testing.T) {
+	builder := newDrunkBishopBuilder(t)
+	request := httptest.NewRequest(http.MethodPost, "/drunk_bishop", strings.NewReader(strings.Repeat("a", maxBindBodySize+1)))
+	recorder := httptest.NewRecorder()
+	builder.Handler().ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusRequestEntityTooLarge || !strings.Contains(recorder.Body.String(), "larger than") {
+		t.Errorf("got status %d, %s", recorder.Code, recorder.Body)
+	}
+}
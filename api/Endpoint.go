@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/swaggest/openapi-go"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Endpoint is a route and its OpenAPI operation, registered together by RouteBuilder.Endpoint so the docs follow the handlers.
+type Endpoint struct {
+	Method      string
+	Path        string
+	Handler     http.HandlerFunc
+	Middlewares []Middleware
+
+	Summary     string
+	Description string
+	Tags        []string
+	// Security are the names of the security schemes of the spec required by the endpoint, e.g. "bearerAuth"
+	Security []string
+	// Request is a pointer to the request type, its query, path, header and json tags document the parameters and the body.
+	// nil when the endpoint takes nothing
+	Request            any
+	RequestContentType string
+	RequestDescription string
+	Responses          []Response
+	// Document completes the operation, e.g. with a second request body
+	Document func(context openapi.OperationContext)
+}
+
+// Response documents a response of an Endpoint.
+type Response struct {
+	Status int
+	// Body is a pointer to the response type, nil when the response has no body
+	Body        any
+	ContentType string
+	Description string
+	// Format is the format of a string Body, e.g. "binary" for the images
+	Format    string
+	IsDefault bool
+}
+
+// Endpoint documents endpoint in the OpenAPI spec, then registers its handler like HandleMethod.
+// Nothing is registered when the operation can't be documented.
+func (b *RouteBuilder) Endpoint(endpoint Endpoint) error {
+	path := b.prefix + endpoint.Path
+	context, err := b.OpenApi.OpenApiReflector.NewOperationContext(endpoint.Method, openApiPath(path))
+	if err != nil {
+		return err
+	}
+	if endpoint.Summary != "" {
+		context.SetSummary(endpoint.Summary)
+	}
+	if endpoint.Description != "" {
+		context.SetDescription(endpoint.Description)
+	}
+	if len(endpoint.Tags) > 0 {
+		context.SetTags(endpoint.Tags...)
+	}
+	for _, security := range endpoint.Security {
+		context.AddSecurity(security)
+	}
+	if endpoint.Request != nil {
+		context.AddReqStructure(endpoint.Request, func(cu *openapi.ContentUnit) {
+			cu.ContentType = endpoint.RequestContentType
+			cu.Description = endpoint.RequestDescription
+		})
+	}
+	for _, response := range endpoint.Responses {
+		context.AddRespStructure(response.Body, func(cu *openapi.ContentUnit) {
+			cu.HTTPStatus = response.Status
+			cu.ContentType = response.ContentType
+			cu.Description = response.Description
+			cu.IsDefault = response.IsDefault
+		})
+	}
+	if endpoint.Document != nil {
+		endpoint.Document(context)
+	}
+	err = b.OpenApi.OpenApiReflector.AddOperation(context)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", endpoint.Method, path, err)
+	}
+	for _, response := range endpoint.Responses {
+		if response.Format != "" {
+			err = b.setResponseFormat(endpoint.Method, path, response)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	b.HandleMethod(endpoint.Method, endpoint.Path, endpoint.Handler, endpoint.Middlewares...)
+	return nil
+}
+
+// setResponseFormat sets the format of the schema of a documented response, which the reflector can't infer from a string.
+func (b *RouteBuilder) setResponseFormat(method string, path string, response Response) error {
+	operation, ok := b.OpenApi.OpenApiReflector.Spec.Paths.MapOfPathItemValues[openApiPath(path)].MapOfOperationValues[strings.ToLower(method)]
+	if !ok {
+		return fmt.Errorf("%s %s: operation not found", method, path)
+	}
+	documented := operation.Responses.Default
+	if !response.IsDefault {
+		status, ok := operation.Responses.MapOfResponseOrRefValues[strconv.Itoa(response.Status)]
+		if ok {
+			documented = &status
+		}
+	}
+	if documented == nil || documented.Response == nil {
+		return fmt.Errorf("%s %s: response %d not found", method, path, response.Status)
+	}
+	content, ok := documented.Response.Content[response.ContentType]
+	if !ok || content.Schema == nil || content.Schema.Schema == nil {
+		return fmt.Errorf("%s %s: response %d has no %s schema", method, path, response.Status, response.ContentType)
+	}
+	format := response.Format
+	content.Schema.Schema.Format = &format
+	return nil
+}
+
+// Verify checks the handlers registered for a method match the operations of the OpenAPI spec:
+// every route must be documented and every operation must have a handler.
+func (b *RouteBuilder) Verify() error {
+	var errs []error
+	routes := make(map[string]bool)
+	for _, path := range slices.Sorted(maps.Keys(b.methods)) {
+		for _, method := range b.methods[path] {
+			route := strings.ToLower(method) + " " + openApiPath(path)
+			routes[route] = true
+			if !b.documented(method, path) {
+				errs = append(errs, fmt.Errorf("%s %s is not documented", method, path))
+			}
+		}
+	}
+	if b.OpenApi.OpenApiReflector.Spec != nil {
+		for _, path := range slices.Sorted(maps.Keys(b.OpenApi.OpenApiReflector.Spec.Paths.MapOfPathItemValues)) {
+			item := b.OpenApi.OpenApiReflector.Spec.Paths.MapOfPathItemValues[path]
+			for _, method := range slices.Sorted(maps.Keys(item.MapOfOperationValues)) {
+				if !routes[method+" "+path] {
+					errs = append(errs, fmt.Errorf("%s %s is documented without a handler", strings.ToUpper(method), path))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *RouteBuilder) documented(method string, path string) bool {
+	if b.OpenApi.OpenApiReflector.Spec == nil {
+		return false
+	}
+	item, ok := b.OpenApi.OpenApiReflector.Spec.Paths.MapOfPathItemValues[openApiPath(path)]
+	if !ok {
+		return false
+	}
+	_, ok = item.MapOfOperationValues[strings.ToLower(method)]
+	return ok
+}
+
+var muxWildcard = regexp.MustCompile(`\{([^}]*)\.\.\.}`)
+
+// openApiPath converts a ServeMux path to its OpenAPI path: "{$}" is dropped and "{name...}" becomes "{name}".
+func openApiPath(path string) string {
+	path = strings.TrimSuffix(path, "{$}")
+	return muxWildcard.ReplaceAllString(path, "{$1}")
+}
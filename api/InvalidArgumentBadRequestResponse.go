@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"httpServer/validation"
+	"net/http"
+	"strconv"
 )
 
 type InvalidArgumentBadRequestResponse struct {
@@ -12,3 +14,17 @@ type InvalidArgumentBadRequestResponse struct {
 func (i *InvalidArgumentBadRequestResponse) ToJson() ([]byte, error) {
 	return json.Marshal(i)
 }
+
+// Write answers 400 Bad Request with i as the JSON body.
+func (i *InvalidArgumentBadRequestResponse) Write(w http.ResponseWriter) {
+	jsonByte, err := i.ToJson()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("Error marshalling response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(jsonByte)))
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write(jsonByte)
+}
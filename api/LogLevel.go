@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"httpServer/logging"
+	"httpServer/services"
+	"httpServer/validation"
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LogLevelRequest changes the level of a category, the levels set here are replaced when logLevel or logLevels is reloaded from the configuration.
+type LogLevelRequest struct {
+	Category    string `json:"category" description:"The category to change, empty for the default level" example:"api.PerlinNoise" default:""`
+	LogLevel    string `json:"logLevel" description:"The new level, empty to remove the level of the category so it uses the level of its parent" example:"Debug" enum:"Lowest,Trace,Verbose,Debug,Information,Warning,Error,Fatal,Highest,"`
+	RevertAfter int    `json:"revertAfter" description:"The seconds after which the previous level is restored, 0 to keep the new level, at most a day" example:"300" default:"0" validate:"min=0,max=86400"`
+}
+
+type LogLevelResponse struct {
+	LogLevel  string            `json:"logLevel" description:"The default level" example:"Information"`
+	LogLevels map[string]string `json:"logLevels" description:"The levels of the categories and their sub categories"`
+	Reverts   map[string]string `json:"reverts" description:"The time each changed category, empty for the default level, is restored at"`
+}
+
+// logLevelController is implemented by logging.LoggerFactory.
+type logLevelController interface {
+	Levels() (logging.LogLevel, map[string]logging.LogLevel)
+	SetLevel(category string, level logging.LogLevel)
+	RemoveLevel(category string)
+}
+
+type logLevels struct {
+	controller logLevelController
+	logger     logging.ILogger
+
+	mutex   sync.Mutex
+	reverts map[string]*logLevelRevert
+}
+
+// logLevelRevert restores the level a category had before the first change of a series of changes.
+type logLevelRevert struct {
+	level    logging.LogLevel
+	hasLevel bool
+	at       time.Time
+	timer    *time.Timer
+}
+
+// RouteLogLevel serves GET and PUT of the levels of the LoggerFactory, only to the callers authenticated by a JWT with the AdminRole.
+// Nothing is served while the JWT credentials are the default ones.
+func RouteLogLevel(path string, builder *RouteBuilder) error {
+	logger := builder.ServiceProvider.CreateLogger("api.LogLevel")
+	controller, ok := builder.ServiceProvider.LoggerFactory.(logLevelController)
+	if !ok {
+		logger.Warning("The logger factory can't change the levels, %s is not served", path)
+		return nil
+	}
+	levels := &logLevels{
+		controller: controller,
+		logger:     logger,
+		reverts:    make(map[string]*logLevelRevert),
+	}
+	if builder.ServiceProvider.Configuration().HasDefaultJwtCredentials() {
+		logger.Warning("The jwtSecret or the jwtIssuer is the default one, set both to serve %s", path)
+		return nil
+	}
+	builder.ServiceProvider.OnConfigurationChanged(func(previous *services.Configuration, current *services.Configuration) {
+		if previous.LogLevel != current.LogLevel || !maps.Equal(previous.LogLevels, current.LogLevels) {
+			// the configured levels replace the changed ones, restoring them later would undo the reload
+			levels.cancelReverts()
+		}
+	})
+	builder.OpenApi.OpenApiReflector.SpecEns().SetHTTPBearerTokenSecurity("bearerAuth", "JWT", "A JWT signed with the jwtSecret and issued by the jwtIssuer of the configuration")
+	unauthorized := Response{Status: http.StatusUnauthorized, Description: "Missing or invalid JWT"}
+	forbidden := Response{Status: http.StatusForbidden, Description: "The JWT lacks the " + services.AdminRole + " role"}
+	middlewares := []Middleware{builder.ServiceProvider.AuthenticationMiddleware, builder.ServiceProvider.RequireRole(services.AdminRole)}
+
+	err := builder.Endpoint(Endpoint{
+		Method: http.MethodGet,
+		Path:   path,
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			levels.writeState(w)
+		},
+		Middlewares: middlewares,
+		Description: "Returns the active log levels",
+		Tags:        []string{"admin"},
+		Security:    []string{"bearerAuth"},
+		Responses: []Response{
+			{Status: http.StatusOK, Body: new(LogLevelResponse), ContentType: "application/json"},
+			unauthorized,
+			forbidden,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		req, invalid := Bind[LogLevelRequest](r)
+		if invalid != nil {
+			invalid.Write(w)
+			return
+		}
+		level, errorsAggregate := req.validate()
+		if len(errorsAggregate.Errors) > 0 {
+			errorsAggregate.Write(w)
+			return
+		}
+		subject := ""
+		if scope := services.RequestScopeFromContext(r.Context()); scope != nil && scope.Principal() != nil {
+			subject = scope.Principal().Subject
+		}
+		builder.Logger(r, "api.LogLevel").Information("%s changed the level of category %q to %q, revert after %ds", subject, req.Category, req.LogLevel, req.RevertAfter)
+		levels.set(req.Category, level, req.LogLevel != "", time.Duration(req.RevertAfter)*time.Second)
+		levels.writeState(w)
+	}
+	return builder.Endpoint(Endpoint{
+		Method:             http.MethodPut,
+		Path:               path,
+		Handler:            handler,
+		Middlewares:        middlewares,
+		Description:        "Changes the default log level or the level of a category until the configuration is reloaded, optionally restoring the previous level after a while",
+		Tags:               []string{"admin"},
+		Security:           []string{"bearerAuth"},
+		Request:            new(LogLevelRequest),
+		RequestContentType: "application/json",
+		Responses: []Response{
+			{Status: http.StatusOK, Body: new(LogLevelResponse), ContentType: "application/json", Description: "The log levels after the change"},
+			{Status: http.StatusBadRequest, Body: new(InvalidArgumentBadRequestResponse), ContentType: "application/json", Description: "Invalid request parameter"},
+			unauthorized,
+			forbidden,
+		},
+	})
+}
+
+func (req *LogLevelRequest) validate() (logging.LogLevel, InvalidArgumentBadRequestResponse) {
+	var errorsAggregate = InvalidArgumentBadRequestResponse{}
+	errorsAggregate.Errors = make(map[string][]*validation.ValidateError)
+	var level logging.LogLevel
+	if req.LogLevel != "" {
+		var err error
+		level, err = logging.ParseLogLevel(req.LogLevel)
+		if err != nil {
+			errorsAggregate.Errors["logLevel"] = []*validation.ValidateError{{Reason: err.Error()}}
+		}
+	} else if req.Category == "" {
+		errorsAggregate.Errors["logLevel"] = []*validation.ValidateError{{Reason: "The default level can't be removed"}}
+	}
+	return level, errorsAggregate
+}
+
+// set changes the level of category, or removes it when hasLevel is false. A pending revert of category is cancelled,
+// the level it would have restored is kept for the new revert so a series of changes reverts to the level before the first one.
+func (l *logLevels) set(category string, level logging.LogLevel, hasLevel bool, revertAfter time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	previous, ok := l.reverts[category]
+	if ok {
+		previous.timer.Stop()
+		delete(l.reverts, category)
+	} else {
+		defaultLevel, categories := l.controller.Levels()
+		previous = &logLevelRevert{level: defaultLevel, hasLevel: true}
+		if category != "" {
+			previous.level, previous.hasLevel = categories[category]
+		}
+	}
+	l.apply(category, level, hasLevel)
+	if revertAfter <= 0 {
+		return
+	}
+	revert := &logLevelRevert{level: previous.level, hasLevel: previous.hasLevel, at: time.Now().Add(revertAfter)}
+	revert.timer = time.AfterFunc(revertAfter, func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if l.reverts[category] != revert {
+			return
+		}
+		delete(l.reverts, category)
+		l.apply(category, revert.level, revert.hasLevel)
+		l.logger.Information("Reverted the level of category %q", category)
+	})
+	l.reverts[category] = revert
+}
+
+func (l *logLevels) cancelReverts() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for category, revert := range l.reverts {
+		revert.timer.Stop()
+		delete(l.reverts, category)
+	}
+}
+
+func (l *logLevels) apply(category string, level logging.LogLevel, hasLevel bool) {
+	if hasLevel {
+		l.controller.SetLevel(category, level)
+	} else {
+		l.controller.RemoveLevel(category)
+	}
+}
+
+func (l *logLevels) writeState(w http.ResponseWriter) {
+	defaultLevel, categories := l.controller.Levels()
+	response := LogLevelResponse{
+		LogLevel:  defaultLevel.String(),
+		LogLevels: make(map[string]string, len(categories)),
+		Reverts:   make(map[string]string),
+	}
+	for category, level := range categories {
+		response.LogLevels[category] = level.String()
+	}
+	l.mutex.Lock()
+	for category, revert := range l.reverts {
+		response.Reverts[category] = revert.at.Format(time.RFC3339)
+	}
+	l.mutex.Unlock()
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("Error marshalling response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(responseBody)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(responseBody)
+}
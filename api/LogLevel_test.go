@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"httpServer/logging"
+	"httpServer/services"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testJwtSecret = "a secret which is long enough to sign the tokens of the tests"
+
+// newLogLevelServer routes the log level endpoint of a LoggerFactory starting with the default level Information and api at Debug.
+// The JWT credentials are the default ones when secured is false.
+func newLogLevelServer(t *testing.T, secured bool) (*RouteBuilder, *logging.LoggerFactory) {
+	t.Helper()
+	sp := services.NewEmptyServiceProvider()
+	factory := logging.NewLoggerFactory(logging.Information, map[string]logging.LogLevel{"api": logging.Debug})
+	_ = factory.SetSinks()
+	sp.AddLoggerFactory(factory)
+	config := services.NewDefaultConfig()
+	if secured {
+		config.JwtSecret = testJwtSecret
+		config.JwtIssuer = "tests"
+	}
+	sp.AddConfiguration(config)
+	builder := NewRouteBuilder(sp, NewOpenApiBuilder())
+	builder.Use(sp.RequestScopeMiddleware)
+	if err := RouteLogLevel("/log_level", builder); err != nil {
+		t.Fatal(err)
+	}
+	if err := builder.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	return builder, factory
+}
+
+// newTestJwt returns a token of the tests signed with secret, claims are added to the registered ones.
+func newTestJwt(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	registered := jwt.MapClaims{
+		"iss": "tests",
+		"sub": "alice",
+		"iat": jwt.NewNumericDate(time.Now()),
+		"exp": jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	maps.Copy(registered, claims)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, registered).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// requestLogLevel serves method on the endpoint with the authorization header and body, both optional.
+func requestLogLevel(builder *RouteBuilder, method string, authorization string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/log_level", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	builder.Handler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+// adminRequest serves method with the token of an admin and decodes the state of a 200 OK response.
+func adminRequest(t *testing.T, builder *RouteBuilder, method string, body string) LogLevelResponse {
+	t.Helper()
+	response := requestLogLevel(builder, method, "Bearer "+newTestJwt(t, testJwtSecret, jwt.MapClaims{"role": services.AdminRole}), body)
+	if response.Code != http.StatusOK {
+		t.Fatalf("%s %s: got status %d, %s", method, body, response.Code, response.Body)
+	}
+	var state LogLevelResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestLogLevelRequiresAnAdminJwt(t *testing.T) {
+	builder, _ := newLogLevelServer(t, true)
+	admin := newTestJwt(t, testJwtSecret, jwt.MapClaims{"role": services.AdminRole})
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"no jwt", "", http.StatusUnauthorized},
+		{"other scheme", "Basic " + admin, http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"other secret", "Bearer " + newTestJwt(t, testJwtSecret+"!", jwt.MapClaims{"role": services.AdminRole}), http.StatusUnauthorized},
+		{"other issuer", "Bearer " + newTestJwt(t, testJwtSecret, jwt.MapClaims{"role": services.AdminRole, "iss": "others"}), http.StatusUnauthorized},
+		{"expired", "Bearer " + newTestJwt(t, testJwtSecret, jwt.MapClaims{"role": services.AdminRole, "exp": jwt.NewNumericDate(time.Now().Add(-time.Minute))}), http.StatusUnauthorized},
+		{"no role", "Bearer " + newTestJwt(t, testJwtSecret, nil), http.StatusForbidden},
+		{"other role", "Bearer " + newTestJwt(t, testJwtSecret, jwt.MapClaims{"roles": []string{"user"}}), http.StatusForbidden},
+		{"admin", "Bearer " + admin, http.StatusOK},
+		{"admin in roles", "Bearer " + newTestJwt(t, testJwtSecret, jwt.MapClaims{"roles": []string{"user", services.AdminRole}}), http.StatusOK},
+		{"lower case scheme", "bearer " + admin, http.StatusOK},
+		{"upper case scheme", "BEARER  " + admin, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, method := range []string{http.MethodGet, http.MethodPut} {
+				response := requestLogLevel(builder, method, test.authorization, `{"category": "api", "logLevel": "Debug"}`)
+				if response.Code != test.status {
+					t.Errorf("%s: got status %d, want %d", method, response.Code, test.status)
+				}
+				if test.status == http.StatusUnauthorized && response.Header().Get("WWW-Authenticate") == "" {
+					t.Errorf("%s: the 401 has no WWW-Authenticate header", method)
+				}
+			}
+		})
+	}
+}
+
+func TestLogLevelIsNotServedWithTheDefaultCredentials(t *testing.T) {
+	builder, factory := newLogLevelServer(t, false)
+	// a token signed with the default secret published in the source code
+	token := newTestJwt(t, services.NewDefaultConfig().JwtSecret.Reveal(), jwt.MapClaims{"role": services.AdminRole, "iss": services.NewDefaultConfig().JwtIssuer})
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		if response := requestLogLevel(builder, method, "Bearer "+token, `{"logLevel": "Trace"}`); response.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d", method, response.Code)
+		}
+	}
+	if defaultLevel, _ := factory.Levels(); defaultLevel != logging.Information {
+		t.Errorf("the default level is changed to %s", defaultLevel)
+	}
+}
+
+func TestLogLevelChangesTheLevels(t *testing.T) {
+	builder, factory := newLogLevelServer(t, true)
+	state := adminRequest(t, builder, http.MethodGet, "")
+	if state.LogLevel != "Information" || len(state.LogLevels) != 1 || state.LogLevels["api"] != "Debug" || len(state.Reverts) != 0 {
+		t.Errorf("got %+v", state)
+	}
+
+	state = adminRequest(t, builder, http.MethodPut, `{"category": "db", "logLevel": "Trace"}`)
+	if state.LogLevels["db"] != "Trace" || state.LogLevels["api"] != "Debug" {
+		t.Errorf("got %+v", state)
+	}
+	state = adminRequest(t, builder, http.MethodPut, `{"logLevel": "Warning"}`)
+	if state.LogLevel != "Warning" {
+		t.Errorf("got %+v", state)
+	}
+	if state = adminRequest(t, builder, http.MethodGet, ""); state.LogLevel != "Warning" || state.LogLevels["db"] != "Trace" {
+		t.Errorf("GET doesn't return the changes: %+v", state)
+	}
+
+	state = adminRequest(t, builder, http.MethodPut, `{"category": "db"}`)
+	if _, ok := state.LogLevels["db"]; ok || state.LogLevel != "Warning" {
+		t.Errorf("the level of db is not removed: %+v", state)
+	}
+	if _, levels := factory.Levels(); len(levels) != 1 {
+		t.Errorf("the factory has the levels %v", levels)
+	}
+}
+
+func TestLogLevelRejectsInvalidChanges(t *testing.T) {
+	builder, factory := newLogLevelServer(t, true)
+	admin := "Bearer " + newTestJwt(t, testJwtSecret, jwt.MapClaims{"role": services.AdminRole})
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"default level removed", `{"category": ""}`, "logLevel"},
+		{"unknown level", `{"category": "api", "logLevel": "Loud"}`, "logLevel"},
+		{"negative revert", `{"category": "api", "logLevel": "Trace", "revertAfter": -1}`, "revertAfter"},
+		{"revert after a day", `{"category": "api", "logLevel": "Trace", "revertAfter": 86401}`, "revertAfter"},
+		{"not json", `api=Trace`, "body"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := requestLogLevel(builder, http.MethodPut, admin, test.body)
+			if response.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d", response.Code)
+			}
+			var invalid InvalidArgumentBadRequestResponse
+			if err := json.Unmarshal(response.Body.Bytes(), &invalid); err != nil || len(invalid.Errors[test.field]) == 0 {
+				t.Errorf("%s is not reported: %s", test.field, response.Body)
+			}
+		})
+	}
+	if defaultLevel, levels := factory.Levels(); defaultLevel != logging.Information || len(levels) != 1 || levels["api"] != logging.Debug {
+		t.Errorf("the levels are changed to %s, %v", defaultLevel, levels)
+	}
+}
+
+// newTestLogLevels returns the logLevels of a factory starting with the default level Information and api at Debug.
+func newTestLogLevels() (*logLevels, *logging.LoggerFactory) {
+	factory := logging.NewLoggerFactory(logging.Information, map[string]logging.LogLevel{"api": logging.Debug})
+	_ = factory.SetSinks()
+	return &logLevels{
+		controller: factory,
+		logger:     factory.CreateLogger("api.LogLevel"),
+		reverts:    make(map[string]*logLevelRevert),
+	}, factory
+}
+
+// waitForReverts waits until levels has no pending revert.
+func waitForReverts(t *testing.T, levels *logLevels) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		levels.mutex.Lock()
+		pending := len(levels.reverts)
+		levels.mutex.Unlock()
+		if pending == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d reverts are still pending", pending)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLogLevelRevertsToTheLevelBeforeTheChanges(t *testing.T) {
+	levels, factory := newTestLogLevels()
+	levels.set("api", logging.Trace, true, time.Hour)
+	levels.set("api", logging.Warning, true, 20*time.Millisecond)
+	levels.set("db", logging.Trace, true, 20*time.Millisecond)
+	levels.set("", logging.Error, true, 10*time.Millisecond)
+	levels.set("", logging.Fatal, true, 20*time.Millisecond)
+	if defaultLevel, categories := factory.Levels(); defaultLevel != logging.Fatal || categories["api"] != logging.Warning || categories["db"] != logging.Trace {
+		t.Fatalf("the changes are not applied: %s, %v", defaultLevel, categories)
+	}
+	waitForReverts(t, levels)
+
+	defaultLevel, categories := factory.Levels()
+	if defaultLevel != logging.Information {
+		t.Errorf("the default level is reverted to %s", defaultLevel)
+	}
+	if categories["api"] != logging.Debug {
+		t.Errorf("api is reverted to %s", categories["api"])
+	}
+	if _, ok := categories["db"]; ok {
+		t.Error("db, which had no level, still has one")
+	}
+}
+
+func TestLogLevelWithoutRevertCancelsThePendingOne(t *testing.T) {
+	levels, factory := newTestLogLevels()
+	levels.set("api", logging.Trace, true, 20*time.Millisecond)
+	levels.set("api", logging.Error, true, 0)
+	time.Sleep(50 * time.Millisecond)
+	if _, categories := factory.Levels(); categories["api"] != logging.Error {
+		t.Errorf("api is reverted to %s", categories["api"])
+	}
+}
+
+func TestConfigurationReloadCancelsTheReverts(t *testing.T) {
+	builder, factory := newLogLevelServer(t, true)
+	state := adminRequest(t, builder, http.MethodPut, `{"category": "api", "logLevel": "Trace", "revertAfter": 300}`)
+	if _, ok := state.Reverts["api"]; !ok {
+		t.Fatalf("the revert is not pending: %+v", state)
+	}
+
+	sp := builder.ServiceProvider
+	unchanged := *sp.Configuration()
+	unchanged.Port++
+	if err := sp.UpdateConfiguration(&unchanged); err != nil {
+		t.Fatal(err)
+	}
+	if state = adminRequest(t, builder, http.MethodGet, ""); len(state.Reverts) != 1 {
+		t.Errorf("a reload without level changes cancels the reverts: %+v", state)
+	}
+
+	reloaded := unchanged
+	reloaded.LogLevels = map[string]logging.LogLevel{"api": logging.Warning}
+	if err := sp.UpdateConfiguration(&reloaded); err != nil {
+		t.Fatal(err)
+	}
+	// the levels are applied to the factory by the subscriber of main, the test does it itself
+	factory.SetLevels(reloaded.LogLevel, reloaded.LogLevels)
+	if state = adminRequest(t, builder, http.MethodGet, ""); len(state.Reverts) != 0 || state.LogLevels["api"] != "Warning" {
+		t.Errorf("the reload doesn't cancel the reverts: %+v", state)
+	}
+}
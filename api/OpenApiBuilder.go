@@ -1,13 +1,61 @@
 package api
 
-import "github.com/swaggest/openapi-go/openapi3"
+import (
+	"github.com/swaggest/jsonschema-go"
+	"github.com/swaggest/openapi-go/openapi3"
+	"httpServer/validation"
+)
 
 type OpenApiBuilder struct {
 	OpenApiReflector *openapi3.Reflector
 }
 
 func NewOpenApiBuilder() *OpenApiBuilder {
+	reflector := openapi3.NewReflector()
+	reflector.DefaultOptions = append(reflector.DefaultOptions, jsonschema.InterceptProp(documentValidateTag))
 	return &OpenApiBuilder{
-		OpenApiReflector: openapi3.NewReflector(),
+		OpenApiReflector: reflector,
 	}
 }
+
+// documentValidateTag adds the rules of the `validate` tag of a field to its schema, or to the schema of its items for a slice.
+func documentValidateTag(params jsonschema.InterceptPropParams) error {
+	tag, ok := params.Field.Tag.Lookup("validate")
+	if !params.Processed || !ok {
+		return nil
+	}
+	rules, err := validation.ParseRules(tag)
+	if err != nil {
+		return err
+	}
+	schema := params.PropertySchema
+	for schema != nil && schema.HasType(jsonschema.Array) {
+		if schema.Items == nil || schema.Items.SchemaOrBool == nil {
+			return nil
+		}
+		schema = schema.Items.SchemaOrBool.TypeObject
+	}
+	if schema == nil || schema.Ref != nil {
+		return nil
+	}
+	if schema.HasType(jsonschema.Integer) || schema.HasType(jsonschema.Number) {
+		schema.Minimum = rules.Min
+		schema.Maximum = rules.Max
+	}
+	if schema.HasType(jsonschema.String) {
+		if rules.MinLength != nil {
+			schema.MinLength = int64(*rules.MinLength)
+		} else if rules.NotEmpty {
+			schema.MinLength = 1
+		}
+		if rules.MaxLength != nil {
+			maxLength := int64(*rules.MaxLength)
+			schema.MaxLength = &maxLength
+		}
+		if rules.Pattern != nil {
+			pattern := rules.Pattern.String()
+			schema.Pattern = &pattern
+		}
+	}
+	return nil
+}
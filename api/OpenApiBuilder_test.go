@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+type documentedParameters struct {
+	Width int      `query:"width" validate:"min=1,max=4096"`
+	Ratio float64  `query:"ratio" validate:"min=0.5"`
+	Tags  []string `query:"tag" validate:"pattern=^[a-z]{1,8}$"`
+	Plain int      `query:"plain"`
+}
+
+type documentedBody struct {
+	Name   string          `json:"name" validate:"notEmpty,maxLength=32,pattern=^[a-z]+(,[a-z]+)*$"`
+	Code   string          `json:"code" validate:"minLength=3"`
+	Scores []int           `json:"scores" validate:"max=100"`
+	Child  documentedChild `json:"child"`
+}
+
+type documentedChild struct {
+	Level int `json:"level" validate:"min=-1"`
+}
+
+// documentedSchemas returns the schemas of the query parameters and of the body components of a POST of request.
+func documentedSchemas(t *testing.T, request any) (map[string]map[string]any, map[string]map[string]any) {
+	t.Helper()
+	builder := NewOpenApiBuilder()
+	context, err := builder.OpenApiReflector.NewOperationContext(http.MethodPost, "/documented")
+	if err != nil {
+		t.Fatal(err)
+	}
+	context.AddReqStructure(request)
+	if err = builder.OpenApiReflector.AddOperation(context); err != nil {
+		t.Fatal(err)
+	}
+	data, err := builder.OpenApiReflector.Spec.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name   string         `json:"name"`
+				Schema map[string]any `json:"schema"`
+			} `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err = json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	parameters := make(map[string]map[string]any)
+	for _, parameter := range spec.Paths["/documented"]["post"].Parameters {
+		parameters[parameter.Name] = parameter.Schema
+	}
+	properties := make(map[string]map[string]any)
+	for name, schema := range spec.Components.Schemas {
+		for property, propertySchema := range schema.Properties {
+			properties[name+"."+property] = propertySchema
+		}
+	}
+	return parameters, properties
+}
+
+func TestOpenApiDocumentsTheValidateTags(t *testing.T) {
+	parameters, _ := documentedSchemas(t, new(documentedParameters))
+	expectSchema(t, "width", parameters["width"], map[string]any{"minimum": 1.0, "maximum": 4096.0})
+	expectSchema(t, "ratio", parameters["ratio"], map[string]any{"minimum": 0.5, "maximum": nil})
+	if items, ok := parameters["tag"]["items"].(map[string]any); !ok || items["pattern"] != "^[a-z]{1,8}$" {
+		t.Errorf("tag: got %v", parameters["tag"])
+	}
+	expectSchema(t, "plain", parameters["plain"], map[string]any{"minimum": nil, "maximum": nil})
+
+	_, properties := documentedSchemas(t, new(documentedBody))
+	expectSchema(t, "name", properties["ApiDocumentedBody.name"], map[string]any{"minLength": 1.0, "maxLength": 32.0, "pattern": "^[a-z]+(,[a-z]+)*$"})
+	expectSchema(t, "code", properties["ApiDocumentedBody.code"], map[string]any{"minLength": 3.0, "maxLength": nil, "pattern": nil})
+	if items, ok := properties["ApiDocumentedBody.scores"]["items"].(map[string]any); !ok || items["maximum"] != 100.0 {
+		t.Errorf("scores: got %v", properties["ApiDocumentedBody.scores"])
+	}
+	expectSchema(t, "child.level", properties["ApiDocumentedChild.level"], map[string]any{"minimum": -1.0})
+}
+
+func expectSchema(t *testing.T, name string, schema map[string]any, expected map[string]any) {
+	t.Helper()
+	if schema == nil {
+		t.Errorf("%s is not documented", name)
+		return
+	}
+	for key, value := range expected {
+		if schema[key] != value {
+			t.Errorf("%s: got %s %v, want %v", name, key, schema[key], value)
+		}
+	}
+}
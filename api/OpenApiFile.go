@@ -1,21 +1,12 @@
 package api
 
 import (
-	"github.com/swaggest/openapi-go"
 	"net/http"
 )
 
-func RouteOpenApiFile(path string, route *RouteBuilder, openapi *OpenApiBuilder) {
-	route.Mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS") // TODO: Dev only
-			return
-		} else if r.Method != http.MethodGet {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			return
-		}
-		marshalJSON, err := openapi.OpenApiReflector.Spec.MarshalJSON()
+func RouteOpenApiFile(path string, builder *RouteBuilder) error {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		marshalJSON, err := builder.OpenApi.OpenApiReflector.Spec.MarshalJSON()
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
@@ -27,28 +18,14 @@ func RouteOpenApiFile(path string, route *RouteBuilder, openapi *OpenApiBuilder)
 		//w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS") // TODO: Dev only
 		_, _ = w.Write(marshalJSON)
 		return
-	})
-}
-
-func ConfigureOpenApiFile(path string, builder *OpenApiBuilder) error {
-	context, err := builder.OpenApiReflector.NewOperationContext(http.MethodGet, path)
-	if err != nil {
-		return err
 	}
-	context.AddRespStructure(new(any), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusOK
-		cu.Description = "The OpenApi file"
-		cu.ContentType = "application/json"
-		cu.IsDefault = true
+	return builder.Endpoint(Endpoint{
+		Method:  http.MethodGet,
+		Path:    path,
+		Handler: handler,
+		Responses: []Response{
+			{Status: http.StatusOK, Body: new(any), ContentType: "application/json", Description: "The OpenApi file", IsDefault: true},
+			{Status: http.StatusInternalServerError, Body: new(any), ContentType: "application/json", Description: "Internal server error"},
+		},
 	})
-	context.AddRespStructure(new(any), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusInternalServerError
-		cu.Description = "Internal server error"
-		cu.ContentType = "application/json"
-	})
-	err = builder.OpenApiReflector.AddOperation(context)
-	if err != nil {
-		return err
-	}
-	return nil
 }
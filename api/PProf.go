@@ -1,7 +1,6 @@
 package api
 
 import (
-	"github.com/swaggest/openapi-go"
 	"net/http"
 	httpPProf "net/http/pprof"
 	runtimePProf "runtime/pprof"
@@ -9,127 +8,86 @@ import (
 	"strings"
 )
 
-func RoutePProf(path string, builder *RouteBuilder) {
-	//builder.Mux.HandleFunc(path, pprof.Index) // this index uses hard-coded route path so it will not work
-	builder.Mux.HandleFunc(path+"/cmdline", httpPProf.Cmdline)
-	builder.Mux.HandleFunc(path+"/profile", httpPProf.Profile)
-	builder.Mux.HandleFunc(path+"/symbol", httpPProf.Symbol)
-	builder.Mux.HandleFunc(path+"/trace", httpPProf.Trace)
+func RoutePProf(path string, builder *RouteBuilder) error {
+	//builder.HandleFunc(path, pprof.Index) // this index uses hard-coded route path so it will not work
+	endpoints := []Endpoint{
+		{
+			Method:      http.MethodGet,
+			Path:        path + "/cmdline",
+			Handler:     httpPProf.Cmdline,
+			Summary:     "cmdline",
+			Description: `Cmdline responds with the running program's command line, with arguments separated by NUL bytes. The package initialization registers it as /debug/pprof/cmdline.`,
+			Responses:   []Response{{Status: http.StatusOK, Body: new(string), ContentType: `text/plain`, Description: `Cmdline responds with the running program's command line, with arguments separated by NUL bytes. The package initialization registers it as /debug/pprof/cmdline.`}},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        path + "/profile",
+			Handler:     httpPProf.Profile,
+			Summary:     "profile",
+			Description: `Profile responds with the pprof-formatted cpu profile. Profiling lasts for duration specified in seconds GET parameter, or for 30 seconds if not specified. The package initialization registers it as /debug/pprof/profile.`,
+			Responses:   []Response{{Status: http.StatusOK, Body: new(string), ContentType: `application/octet-stream`, Description: `Profile responds with the pprof-formatted cpu profile. Profiling lasts for duration specified in seconds GET parameter, or for 30 seconds if not specified. The package initialization registers it as /debug/pprof/profile.`}},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        path + "/symbol",
+			Handler:     httpPProf.Symbol,
+			Summary:     "symbol",
+			Description: `Symbol looks up the program counters listed in the request, responding with a table mapping program counters to function names. The package initialization registers it as /debug/pprof/symbol.`,
+			Responses:   []Response{{Status: http.StatusOK, Body: new(string), ContentType: `text/plain`, Description: `Symbol looks up the program counters listed in the request, responding with a table mapping program counters to function names. The package initialization registers it as /debug/pprof/symbol.`}},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        path + "/symbol",
+			Handler:     httpPProf.Symbol,
+			Summary:     "symbol",
+			Description: `Symbol looks up the program counters listed in the request body, responding with a table mapping program counters to function names.`,
+			Responses:   []Response{{Status: http.StatusOK, Body: new(string), ContentType: `text/plain`, Description: `Symbol looks up the program counters listed in the request body, responding with a table mapping program counters to function names.`}},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        path + "/trace",
+			Handler:     httpPProf.Trace,
+			Summary:     "trace",
+			Description: `Trace responds with the execution trace in binary form. Tracing lasts for duration specified in seconds GET parameter, or for 1 second if not specified. The package initialization registers it as /debug/pprof/trace.`,
+			Responses:   []Response{{Status: http.StatusOK, Body: new(string), ContentType: `application/octet-stream`, Description: `Trace responds with the execution trace in binary form. Tracing lasts for duration specified in seconds GET parameter, or for 1 second if not specified. The package initialization registers it as /debug/pprof/trace.`}},
+		},
+	}
 	// Replaced with dynamic resolved runtime/pprof.Profile
-	//builder.Mux.HandleFunc(path+"/allocs", pprof.Handler("allocs").ServeHTTP)
-	//builder.Mux.HandleFunc(path+"/block", pprof.Handler("block").ServeHTTP)
-	//builder.Mux.HandleFunc(path+"/goroutine", pprof.Handler("goroutine").ServeHTTP)
-	//builder.Mux.HandleFunc(path+"/heap", pprof.Handler("heap").ServeHTTP)
-	//builder.Mux.HandleFunc(path+"/mutex", pprof.Handler("mutex").ServeHTTP)
-	//builder.Mux.HandleFunc(path+"/threadcreate", pprof.Handler("threadcreate").ServeHTTP)
+	//builder.HandleFunc(path+"/allocs", pprof.Handler("allocs").ServeHTTP)
+	//builder.HandleFunc(path+"/block", pprof.Handler("block").ServeHTTP)
+	//builder.HandleFunc(path+"/goroutine", pprof.Handler("goroutine").ServeHTTP)
+	//builder.HandleFunc(path+"/heap", pprof.Handler("heap").ServeHTTP)
+	//builder.HandleFunc(path+"/mutex", pprof.Handler("mutex").ServeHTTP)
+	//builder.HandleFunc(path+"/threadcreate", pprof.Handler("threadcreate").ServeHTTP)
 
 	profiles := runtimePProf.Profiles()
 	for _, profile := range profiles {
-		builder.ServiceProvider.Logger.Debug("Registering pprof profile %s to endpoint %s/%s:", profile.Name(), path, profile.Name())
-		builder.Mux.HandleFunc(path+"/"+profile.Name(), func(w http.ResponseWriter, r *http.Request) {
-			debugStr := r.URL.Query().Get("debug")
-			debug := 0
-			if debugStr != "" {
-				var err error
-				debug, err = strconv.Atoi(debugStr)
-				if err != nil {
-					w.WriteHeader(http.StatusBadRequest)
-					_, _ = w.Write([]byte("Invalid debug parameter"))
-					return
+		builder.ServiceProvider.CreateLogger("api.PProf").Debug("Registering pprof profile %s to endpoint %s/%s:", profile.Name(), path, profile.Name())
+		endpoints = append(endpoints, Endpoint{
+			Method: http.MethodGet,
+			Path:   path + "/" + profile.Name(),
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				debugStr := r.URL.Query().Get("debug")
+				debug := 0
+				if debugStr != "" {
+					var err error
+					debug, err = strconv.Atoi(debugStr)
+					if err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						_, _ = w.Write([]byte("Invalid debug parameter"))
+						return
+					}
 				}
-			}
-			_ = profile.WriteTo(w, debug)
+				_ = profile.WriteTo(w, debug)
+			},
+			Summary:     profile.Name(),
+			Description: `Count ` + strconv.Itoa(profile.Count()) + "\n" + getDescriptionOrDefault(profile.Name()),
+			Responses:   []Response{{Status: http.StatusOK, Body: new(string), ContentType: `application/octet-stream`, Description: getDescriptionOrDefault(profile.Name())}},
 		})
 	}
-}
-
-func ConfigurePProf(path string, builder *OpenApiBuilder) error {
-	var err error
-	var context openapi.OperationContext
-	// cmdline
-	context, err = builder.OpenApiReflector.NewOperationContext(http.MethodGet, path+"/cmdline")
-	if err != nil {
-		return err
-	}
-	context.SetTags("debug")
-	context.SetSummary("cmdline")
-	context.SetDescription(`Cmdline responds with the running program's command line, with arguments separated by NUL bytes. The package initialization registers it as /debug/pprof/cmdline.`)
-	context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusOK
-		cu.ContentType = `text/plain`
-		cu.Description = `Cmdline responds with the running program's command line, with arguments separated by NUL bytes. The package initialization registers it as /debug/pprof/cmdline.`
-	})
-	err = builder.OpenApiReflector.AddOperation(context)
-	if err != nil {
-		return err
-	}
-
-	context, err = builder.OpenApiReflector.NewOperationContext(http.MethodGet, path+"/profile")
-	if err != nil {
-		return err
-	}
-	context.SetTags("debug")
-	context.SetSummary("profile")
-	context.SetDescription(`Profile responds with the pprof-formatted cpu profile. Profiling lasts for duration specified in seconds GET parameter, or for 30 seconds if not specified. The package initialization registers it as /debug/pprof/profile.`)
-	context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusOK
-		cu.ContentType = `application/octet-stream`
-		cu.Description = `Profile responds with the pprof-formatted cpu profile. Profiling lasts for duration specified in seconds GET parameter, or for 30 seconds if not specified. The package initialization registers it as /debug/pprof/profile.`
-	})
-	err = builder.OpenApiReflector.AddOperation(context)
-	if err != nil {
-		return err
-	}
-
-	context, err = builder.OpenApiReflector.NewOperationContext(http.MethodGet, path+"/symbol")
-	if err != nil {
-		return err
-	}
-	context.SetTags("debug")
-	context.SetSummary("symbol")
-	context.SetDescription(`Symbol looks up the program counters listed in the request, responding with a table mapping program counters to function names. The package initialization registers it as /debug/pprof/symbol.`)
-	context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusOK
-		cu.ContentType = `text/plain`
-		cu.Description = `Symbol looks up the program counters listed in the request, responding with a table mapping program counters to function names. The package initialization registers it as /debug/pprof/symbol.`
-	})
-	err = builder.OpenApiReflector.AddOperation(context)
-	if err != nil {
-		return err
-	}
-
-	context, err = builder.OpenApiReflector.NewOperationContext(http.MethodGet, path+"/trace")
-	if err != nil {
-		return err
-	}
-	context.SetTags("debug")
-	context.SetSummary("trace")
-	context.SetDescription(`Trace responds with the execution trace in binary form. Tracing lasts for duration specified in seconds GET parameter, or for 1 second if not specified. The package initialization registers it as /debug/pprof/trace.`)
-	context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-		cu.HTTPStatus = http.StatusOK
-		cu.ContentType = `application/octet-stream`
-		cu.Description = `Trace responds with the execution trace in binary form. Tracing lasts for duration specified in seconds GET parameter, or for 1 second if not specified. The package initialization registers it as /debug/pprof/trace.`
-	})
-	err = builder.OpenApiReflector.AddOperation(context)
-	if err != nil {
-		return err
-	}
-
-	profiles := runtimePProf.Profiles()
-	for _, profile := range profiles {
-		context, err = builder.OpenApiReflector.NewOperationContext(http.MethodGet, path+"/"+profile.Name())
-		if err != nil {
-			return err
-		}
-		context.SetTags("debug")
-		context.SetSummary(profile.Name())
-		context.SetDescription(`Count ` + strconv.Itoa(profile.Count()) + "\n" + getDescriptionOrDefault(profile.Name()))
-		context.AddRespStructure(new(string), func(cu *openapi.ContentUnit) {
-			cu.HTTPStatus = http.StatusOK
-			cu.ContentType = `application/octet-stream`
-			cu.Description = getDescriptionOrDefault(profile.Name())
-		})
-		err = builder.OpenApiReflector.AddOperation(context)
+	for _, endpoint := range endpoints {
+		endpoint.Tags = []string{"debug"}
+		err := builder.Endpoint(endpoint)
 		if err != nil {
 			return err
 		}
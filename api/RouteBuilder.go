@@ -1,19 +1,179 @@
 package api
 
 import (
+	"httpServer/logging"
 	"httpServer/services"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 )
 
+// Middleware wraps the handler of the next step of a pipeline, e.g. services.ServiceProvider.AuthenticationMiddleware.
+type Middleware func(next http.Handler) http.Handler
+
+// RouteBuilder registers the handlers on Mux behind middleware chains. A request goes through, from the outermost:
+// the middlewares of Use on the root builder, which also see the unmatched requests, then the ones of every Group
+// from the outermost, then the ones given to Handle. Each list runs in the order it was given,
+// whatever the order of the Use and Handle calls, so the chains must be complete before the first request.
 type RouteBuilder struct {
 	Mux             *http.ServeMux
 	ServiceProvider *services.ServiceProvider
+	// OpenApi documents the routes registered by Endpoint
+	OpenApi *OpenApiBuilder
+
+	// parent is nil for the root builder
+	parent      *RouteBuilder
+	prefix      string
+	middlewares []Middleware
+	// methods are the methods registered by path, shared by the builder and its groups
+	methods map[string][]string
 }
 
-func NewRouteBuilder(serviceProvider *services.ServiceProvider) *RouteBuilder {
+func NewRouteBuilder(serviceProvider *services.ServiceProvider, openApi *OpenApiBuilder) *RouteBuilder {
 	builder := &RouteBuilder{
 		Mux:             http.NewServeMux(),
 		ServiceProvider: serviceProvider,
+		OpenApi:         openApi,
+		methods:         make(map[string][]string),
 	}
 	return builder
 }
+
+// Use appends middlewares to the chain of the builder, for every route of the builder and of its groups.
+func (b *RouteBuilder) Use(middlewares ...Middleware) {
+	b.middlewares = append(b.middlewares, middlewares...)
+}
+
+// Group returns a builder registering its routes under prefix, behind the chain of b followed by middlewares.
+func (b *RouteBuilder) Group(prefix string, middlewares ...Middleware) *RouteBuilder {
+	return &RouteBuilder{
+		Mux:             b.Mux,
+		ServiceProvider: b.ServiceProvider,
+		OpenApi:         b.OpenApi,
+		parent:          b,
+		prefix:          b.prefix + prefix,
+		middlewares:     slices.Clone(middlewares),
+		methods:         b.methods,
+	}
+}
+
+// Handle registers handler for every method of the prefix of the builder followed by path,
+// behind the chain of the builder followed by middlewares.
+func (b *RouteBuilder) Handle(path string, handler http.Handler, middlewares ...Middleware) {
+	b.handle(b.prefix+path, handler, middlewares)
+}
+
+func (b *RouteBuilder) HandleFunc(path string, handler func(http.ResponseWriter, *http.Request), middlewares ...Middleware) {
+	b.Handle(path, http.HandlerFunc(handler), middlewares...)
+}
+
+// HandleMethod registers handler for method only, like Handle. GET also serves HEAD.
+// The other methods of the path get 405 Method Not Allowed, and OPTIONS a 204 No Content, with the Allow header of the registered methods.
+// Neither goes through the middlewares of the route or of the groups, only the ones of the root builder,
+// so a preflight request doesn't need the credentials.
+func (b *RouteBuilder) HandleMethod(method string, path string, handler http.Handler, middlewares ...Middleware) {
+	path = b.prefix + path
+	if _, ok := b.methods[path]; !ok {
+		// the method patterns are more specific, so this only gets the methods without a handler
+		b.Mux.Handle(path, newLazyHandler(func() http.Handler {
+			return newMethodNotAllowedHandler(b.methods[path])
+		}))
+	}
+	b.methods[path] = append(b.methods[path], method)
+	b.handle(method+" "+path, handler, middlewares)
+}
+
+func (b *RouteBuilder) Get(path string, handler func(http.ResponseWriter, *http.Request), middlewares ...Middleware) {
+	b.HandleMethod(http.MethodGet, path, http.HandlerFunc(handler), middlewares...)
+}
+
+func (b *RouteBuilder) Post(path string, handler func(http.ResponseWriter, *http.Request), middlewares ...Middleware) {
+	b.HandleMethod(http.MethodPost, path, http.HandlerFunc(handler), middlewares...)
+}
+
+func (b *RouteBuilder) Put(path string, handler func(http.ResponseWriter, *http.Request), middlewares ...Middleware) {
+	b.HandleMethod(http.MethodPut, path, http.HandlerFunc(handler), middlewares...)
+}
+
+func (b *RouteBuilder) Delete(path string, handler func(http.ResponseWriter, *http.Request), middlewares ...Middleware) {
+	b.HandleMethod(http.MethodDelete, path, http.HandlerFunc(handler), middlewares...)
+}
+
+// handle registers the Mux pattern behind the chain of the builder followed by middlewares.
+func (b *RouteBuilder) handle(pattern string, handler http.Handler, middlewares []Middleware) {
+	middlewares = slices.Clone(middlewares)
+	b.Mux.Handle(pattern, newLazyHandler(func() http.Handler {
+		handler = chain(handler, middlewares)
+		// the root middlewares are applied by Handler
+		for group := b; group.parent != nil; group = group.parent {
+			handler = chain(handler, group.middlewares)
+		}
+		return handler
+	}))
+}
+
+// Handler returns the handler of the server: Mux behind the chain of the root builder.
+func (b *RouteBuilder) Handler() http.Handler {
+	root := b
+	for root.parent != nil {
+		root = root.parent
+	}
+	return newLazyHandler(func() http.Handler {
+		return chain(root.Mux, root.middlewares)
+	})
+}
+
+// Logger returns a logger of category carrying the request fields, or a plain logger of category when the request has no scope.
+func (b *RouteBuilder) Logger(r *http.Request, category string) logging.ILogger {
+	if scope := services.RequestScopeFromContext(r.Context()); scope != nil {
+		return scope.CreateLogger(category)
+	}
+	return b.ServiceProvider.CreateLogger(category)
+}
+
+// newMethodNotAllowedHandler answers OPTIONS and rejects the other methods, with the Allow header of methods.
+func newMethodNotAllowedHandler(methods []string) http.Handler {
+	allowed := slices.Clone(methods)
+	if slices.Contains(allowed, http.MethodGet) {
+		allowed = append(allowed, http.MethodHead)
+	}
+	allowed = append(allowed, http.MethodOptions)
+	slices.Sort(allowed)
+	allow := strings.Join(slices.Compact(allowed), ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", allow)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+}
+
+// chain wraps handler so the middlewares run in order, the first one being the outermost.
+func chain(handler http.Handler, middlewares []Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// lazyHandler builds its handler on the first request, once the middlewares are all known.
+type lazyHandler struct {
+	once    sync.Once
+	build   func() http.Handler
+	handler http.Handler
+}
+
+func newLazyHandler(build func() http.Handler) *lazyHandler {
+	return &lazyHandler{build: build}
+}
+
+func (h *lazyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.handler = h.build()
+	})
+	h.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// markingMiddleware adds name to the X-Middlewares header of the response, and answers 401 when deny is set.
+func markingMiddleware(name string, deny bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middlewares", name)
+			if deny {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newTestRouteBuilder() *RouteBuilder {
+	builder := NewRouteBuilder(nil, nil)
+	builder.Use(markingMiddleware("root", false))
+	group := builder.Group("/api", markingMiddleware("group", true))
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}
+	group.Get("/items", ok)
+	group.Post("/items", ok, markingMiddleware("route", false))
+	group.Delete("/items/{id}", ok)
+	return builder
+}
+
+func serve(builder *RouteBuilder, method string, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	builder.Handler().ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+func TestHandleMethodRejectsTheOtherMethods(t *testing.T) {
+	builder := newTestRouteBuilder()
+	tests := []struct {
+		method string
+		target string
+		allow  string
+	}{
+		{http.MethodPut, "/api/items", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodPatch, "/api/items", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodGet, "/api/items/1", "DELETE, OPTIONS"},
+	}
+	for _, test := range tests {
+		response := serve(builder, test.method, test.target)
+		if response.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: got status %d", test.method, test.target, response.Code)
+		}
+		if allow := response.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s %s: got Allow %q, want %q", test.method, test.target, allow, test.allow)
+		}
+		if middlewares := response.Header().Values("X-Middlewares"); len(middlewares) != 1 || middlewares[0] != "root" {
+			t.Errorf("%s %s: went through the middlewares %v", test.method, test.target, middlewares)
+		}
+	}
+}
+
+func TestHandleMethodAnswersOptions(t *testing.T) {
+	response := serve(newTestRouteBuilder(), http.MethodOptions, "/api/items")
+	if response.Code != http.StatusNoContent {
+		t.Errorf("got status %d", response.Code)
+	}
+	for _, header := range []string{"Allow", "Access-Control-Allow-Methods"} {
+		if allow := response.Header().Get(header); allow != "GET, HEAD, OPTIONS, POST" {
+			t.Errorf("got %s %q", header, allow)
+		}
+	}
+	// the preflight requests have no credentials, the group middleware would deny them
+	if middlewares := response.Header().Values("X-Middlewares"); len(middlewares) != 1 || middlewares[0] != "root" {
+		t.Errorf("went through the middlewares %v", middlewares)
+	}
+}
+
+func TestHandleMethodRunsTheChainOfTheRoute(t *testing.T) {
+	builder := NewRouteBuilder(nil, nil)
+	builder.Use(markingMiddleware("root", false))
+	group := builder.Group("/api", markingMiddleware("group", false))
+	group.Post("/items", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}, markingMiddleware("route", false))
+
+	response := serve(builder, http.MethodPost, "/api/items")
+	if response.Code != http.StatusOK || response.Body.String() != "ok" {
+		t.Errorf("got %d %q", response.Code, response.Body.String())
+	}
+	middlewares := response.Header().Values("X-Middlewares")
+	if len(middlewares) != 3 || middlewares[0] != "root" || middlewares[1] != "group" || middlewares[2] != "route" {
+		t.Errorf("went through the middlewares %v", middlewares)
+	}
+
+	if response = serve(newTestRouteBuilder(), http.MethodGet, "/api/items"); response.Code != http.StatusUnauthorized {
+		t.Errorf("the group middleware doesn't run, got status %d", response.Code)
+	}
+}
+
+type verifiedRequest struct {
+	Id string `path:"id"`
+}
+
+type verifiedFileRequest struct {
+	Path string `path:"path"`
+}
+
+func TestVerifyMatchesTheHandlersAndTheOperations(t *testing.T) {
+	builder := NewRouteBuilder(nil, NewOpenApiBuilder())
+	group := builder.Group("/api")
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	for _, endpoint := range []Endpoint{
+		{Method: http.MethodGet, Path: "/items", Handler: ok},
+		{Method: http.MethodDelete, Path: "/items/{id}", Handler: ok, Request: &verifiedRequest{}},
+		{Method: http.MethodGet, Path: "/files/{path...}", Handler: ok, Request: &verifiedFileRequest{}},
+		{Method: http.MethodGet, Path: "/{$}", Handler: ok},
+	} {
+		if err := group.Endpoint(endpoint); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := builder.Verify(); err != nil {
+		t.Errorf("the endpoints don't match their operations: %v", err)
+	}
+}
+
+func TestVerifyReportsTheUndocumentedHandlers(t *testing.T) {
+	builder := NewRouteBuilder(nil, NewOpenApiBuilder())
+	if err := builder.Endpoint(Endpoint{Method: http.MethodGet, Path: "/items", Handler: func(http.ResponseWriter, *http.Request) {}}); err != nil {
+		t.Fatal(err)
+	}
+	builder.Group("/api").Post("/items", func(http.ResponseWriter, *http.Request) {})
+	builder.Put("/items", func(http.ResponseWriter, *http.Request) {})
+
+	err := builder.Verify()
+	expected := "POST /api/items is not documented\nPUT /items is not documented"
+	if err == nil || err.Error() != expected {
+		t.Errorf("got %v, want %q", err, expected)
+	}
+}
+
+func TestVerifyReportsTheOperationsWithoutHandler(t *testing.T) {
+	builder := NewRouteBuilder(nil, NewOpenApiBuilder())
+	if err := builder.Endpoint(Endpoint{Method: http.MethodGet, Path: "/items", Handler: func(http.ResponseWriter, *http.Request) {}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, operation := range []struct {
+		method, path string
+		request      any
+	}{{http.MethodPost, "/items", nil}, {http.MethodGet, "/ghosts/{id}", &verifiedRequest{}}} {
+		context, err := builder.OpenApi.OpenApiReflector.NewOperationContext(operation.method, operation.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if operation.request != nil {
+			context.AddReqStructure(operation.request)
+		}
+		if err = builder.OpenApi.OpenApiReflector.AddOperation(context); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := builder.Verify()
+	expected := "GET /ghosts/{id} is documented without a handler\nPOST /items is documented without a handler"
+	if err == nil || err.Error() != expected {
+		t.Errorf("got %v, want %q", err, expected)
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"os"
 )
 
-func RouteScalarClient(path string, builder *RouteBuilder) {
+func RouteScalarClient(path string, builder *RouteBuilder) error {
 	page, readScalarErr := os.ReadFile("assets/ScalarApiClient.html") // TODO: This is an html to cdn, use server only static files
-	builder.Mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
 		if readScalarErr != nil {
 			http.NotFound(w, r)
 		}
@@ -16,5 +16,14 @@ func RouteScalarClient(path string, builder *RouteBuilder) {
 		//w.Header().Set("Access-Control-Allow-Origin", "*")                   // TODO: Dev only
 		//w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS") // TODO: Dev only
 		w.Write(page)
+	}
+	return builder.Endpoint(Endpoint{
+		Method:      http.MethodGet,
+		Path:        path,
+		Handler:     handler,
+		Description: "The Scalar client of this OpenApi file",
+		Responses: []Response{
+			{Status: http.StatusOK, Body: new(string), ContentType: "text/html", Description: "The Scalar client page", IsDefault: true},
+		},
 	})
 }
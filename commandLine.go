@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"httpServer/logging"
+	"httpServer/services"
+	"io/fs"
+	"os"
+)
+
+const defaultConfigPath = "config.json"
+
+const usage = `Usage:
+  httpServer [serve] [flags]          start the server
+  httpServer config init [flags]      write a config file with the default values, --force to overwrite
+  httpServer config validate [flags]  check a config file without starting the server
+
+Run "httpServer <command> -h" for the flags of a command.
+`
+
+// executeCommandLine dispatches args (without the program name) to a subcommand and returns the process exit code.
+func executeCommandLine(args []string) int {
+	if len(args) == 0 || args[0] == "" || args[0][0] == '-' {
+		return serveCommand(args)
+	}
+	switch args[0] {
+	case "serve":
+		return serveCommand(args[1:])
+	case "config":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		switch args[1] {
+		case "init":
+			return configInitCommand(args[2:])
+		case "validate":
+			return configValidateCommand(args[2:])
+		}
+		fmt.Fprintf(os.Stderr, "unknown config subcommand %q, expected init or validate\n%s", args[1], usage)
+		return 2
+	case "help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n%s", args[0], usage)
+	return 2
+}
+
+// parseFlags parses args into set and converts the outcome to an exit code, ok is false when the command should stop.
+func parseFlags(set *flag.FlagSet, args []string) (code int, ok bool) {
+	err := set.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0, false
+	}
+	if err != nil {
+		return 2, false
+	}
+	if set.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected argument %q\n", set.Arg(0))
+		return 2, false
+	}
+	return 0, true
+}
+
+func serveCommand(args []string) int {
+	set := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := set.String("config", defaultConfigPath, "path of the json config file")
+	configurationFlags := services.NewConfigurationFlags(set)
+	if code, ok := parseFlags(set, args); !ok {
+		return code
+	}
+	return serve(*configPath, configurationFlags)
+}
+
+func configInitCommand(args []string) int {
+	set := flag.NewFlagSet("config init", flag.ContinueOnError)
+	configPath := set.String("config", defaultConfigPath, "path of the json config file to create")
+	force := set.Bool("force", false, "overwrite the config file if it already exists")
+	if code, ok := parseFlags(set, args); !ok {
+		return code
+	}
+	log := configureLogger(logging.Information)
+	configFile, err := services.NewDefaultConfig().MarshalFile()
+	if err != nil {
+		log.Error("Error creating config file: %v", err)
+		return 1
+	}
+	// O_EXCL so an existing operator file is never overwritten unless forced
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(*configPath, flags, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		log.Error("Config file %s already exists, use --force to overwrite it", *configPath)
+		return 1
+	}
+	if err != nil {
+		log.Error("Error creating config file: %v", err)
+		return 1
+	}
+	_, err = file.Write(configFile)
+	closeErr := file.Close()
+	if err = errors.Join(err, closeErr); err != nil {
+		log.Error("Error writing config file %s: %v", *configPath, err)
+		return 1
+	}
+	log.Information("Config file %s created with default values", *configPath)
+	return 0
+}
+
+func configValidateCommand(args []string) int {
+	set := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	configPath := set.String("config", defaultConfigPath, "path of the json config file to check")
+	if code, ok := parseFlags(set, args); !ok {
+		return code
+	}
+	log := configureLogger(logging.Information)
+	loader := services.NewConfigurationLoader()
+	err := loader.ApplyJsonFile(*configPath)
+	if err != nil {
+		log.Error("Error reading config file: %v", err)
+		return 1
+	}
+	err = loader.Configuration().Validate()
+	if err != nil {
+		log.Error("Config file %s is invalid: %v", *configPath, err)
+		return 1
+	}
+	log.Information("Config file %s is valid", *configPath)
+	return 0
+}
@@ -6,11 +6,11 @@ require (
 	github.com/aquilax/go-perlin v1.1.0
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/swaggest/jsonschema-go v0.3.73
 	github.com/swaggest/openapi-go v0.2.57
 )
 
 require (
-	github.com/swaggest/jsonschema-go v0.3.73 // indirect
 	github.com/swaggest/refl v1.3.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
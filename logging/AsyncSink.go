@@ -0,0 +1,235 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IFlusher is implemented by the sinks and factories buffering entries, Flush returns once the buffered entries are written.
+type IFlusher interface {
+	Flush() error
+}
+
+// OverflowPolicy tells what an AsyncSink does with an entry when its queue is full.
+type OverflowPolicy string
+
+const (
+	// BlockPolicy waits for the queue to have room, the logging goroutine is slowed down but no entry is lost
+	BlockPolicy OverflowPolicy = "block"
+	// DropPolicy discards the entry and counts it, the logging goroutine never waits
+	DropPolicy OverflowPolicy = "drop"
+)
+
+// ParseOverflowPolicy parses the name of an OverflowPolicy.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch OverflowPolicy(s) {
+	case BlockPolicy, DropPolicy:
+		return OverflowPolicy(s), nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy: %s", s)
+	}
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (p *OverflowPolicy) UnmarshalJSON(bytes []byte) error {
+	var s string
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return err
+	}
+	policy, err := ParseOverflowPolicy(s)
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// AsyncSink queues the entries and writes them to the wrapped sink on its own goroutine,
+// so the logging goroutine doesn't wait for the formatting and the I/O.
+type AsyncSink struct {
+	sink   ISink
+	policy OverflowPolicy
+	queue  chan asyncRequest
+	done   chan struct{}
+
+	dropped  atomic.Uint64
+	reported uint64
+
+	// mutex guards closed so no request is sent once the queue is closed
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// asyncRequest is an entry to write, or a flush when flushed is not nil.
+type asyncRequest struct {
+	entry   *Entry
+	flushed chan error
+}
+
+// NewAsyncSink wraps sink with a queue of size entries, policy tells what to do when the queue is full.
+func NewAsyncSink(sink ISink, size int, policy OverflowPolicy) *AsyncSink {
+	s := &AsyncSink{
+		sink:   sink,
+		policy: policy,
+		queue:  make(chan asyncRequest, size),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *AsyncSink) Level() LogLevel {
+	return s.sink.Level()
+}
+
+// Write queues a snapshot of entry, it only fails when the sink is closed.
+func (s *AsyncSink) Write(entry *Entry) error {
+	entry = snapshotEntry(entry)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return errors.New("log sink is closed")
+	}
+	if s.policy == DropPolicy {
+		select {
+		case s.queue <- asyncRequest{entry: entry}:
+		default:
+			s.dropped.Add(1)
+		}
+		return nil
+	}
+	s.queue <- asyncRequest{entry: entry}
+	return nil
+}
+
+// Dropped returns the number of entries discarded because the queue was full.
+func (s *AsyncSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Flush waits for the entries queued before it to be written, then flushes the wrapped sink.
+func (s *AsyncSink) Flush() error {
+	s.mutex.RLock()
+	if s.closed {
+		s.mutex.RUnlock()
+		return nil
+	}
+	flushed := make(chan error, 1)
+	// a flush is never dropped, even with DropPolicy
+	s.queue <- asyncRequest{flushed: flushed}
+	s.mutex.RUnlock()
+	return <-flushed
+}
+
+// Close writes the queued entries, then closes the wrapped sink.
+func (s *AsyncSink) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mutex.Unlock()
+	<-s.done
+	return s.sink.Close()
+}
+
+func (s *AsyncSink) run() {
+	defer close(s.done)
+	for request := range s.queue {
+		if request.flushed != nil {
+			s.reportDropped()
+			var err error
+			if flusher, ok := s.sink.(IFlusher); ok {
+				err = flusher.Flush()
+			}
+			request.flushed <- err
+			continue
+		}
+		s.write(request.entry)
+		s.reportDropped()
+	}
+	s.reportDropped()
+}
+
+// reportDropped writes a warning to the wrapped sink when entries were dropped since the last report.
+func (s *AsyncSink) reportDropped() {
+	dropped := s.dropped.Load()
+	if dropped == s.reported {
+		return
+	}
+	count := dropped - s.reported
+	s.reported = dropped
+	template := "Dropped %d log entries, the log queue was full"
+	s.write(&Entry{
+		Time:     time.Now(),
+		Level:    Warning,
+		Category: "logging",
+		Template: template,
+		Message:  fmt.Sprintf(template, count),
+		Args:     []interface{}{count},
+	})
+}
+
+// snapshotEntry returns a copy of entry whose arguments and fields are rendered now, on the logging goroutine:
+// the caller may change a logged map or struct as soon as Log returned, while the sink goroutine formats it.
+func snapshotEntry(entry *Entry) *Entry {
+	snapshot := *entry
+	if len(entry.Args) > 0 {
+		snapshot.Args = make([]interface{}, len(entry.Args))
+		for i, arg := range entry.Args {
+			snapshot.Args[i] = snapshotValue(arg)
+		}
+	}
+	if len(entry.Fields) > 0 {
+		snapshot.Fields = make([]Field, len(entry.Fields))
+		for i, field := range entry.Fields {
+			snapshot.Fields[i] = Field{Key: field.Key, Value: snapshotValue(field.Value)}
+		}
+	}
+	return &snapshot
+}
+
+// snapshotValue keeps the immutable values, the others are rendered as the formatters would render them.
+func snapshotValue(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, Secret, bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, renderedValue:
+		return value
+	}
+	rendered := renderedValue{text: fmt.Sprint(value)}
+	var err error
+	rendered.json, err = json.Marshal(jsonValue(value))
+	if err != nil {
+		rendered.json, _ = json.Marshal(rendered.text)
+	}
+	return rendered
+}
+
+// renderedValue is a value rendered by snapshotValue, both as the text of fmt.Sprint and as the JSON of the JsonFormatter.
+type renderedValue struct {
+	text string
+	json json.RawMessage
+}
+
+func (v renderedValue) String() string {
+	return v.text
+}
+
+func (v renderedValue) MarshalJSON() ([]byte, error) {
+	return v.json, nil
+}
+
+func (s *AsyncSink) write(entry *Entry) {
+	if entry.Level < s.sink.Level() {
+		return
+	}
+	err := s.sink.Write(entry)
+	if err != nil {
+		reportSinkError(err)
+	}
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedSink formats the entries once gate is closed, so the test decides when the sink goroutine reads them.
+type gatedSink struct {
+	gate      chan struct{}
+	formatter IFormatter
+
+	mutex sync.Mutex
+	lines bytes.Buffer
+}
+
+func (s *gatedSink) Level() LogLevel {
+	return Lowest
+}
+
+func (s *gatedSink) Write(entry *Entry) error {
+	<-s.gate
+	line := s.formatter.Format(entry)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lines.Write(line)
+	return nil
+}
+
+func (s *gatedSink) Close() error {
+	return nil
+}
+
+func (s *gatedSink) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lines.String()
+}
+
+func TestAsyncSinkFormatsTheValuesOfTheCall(t *testing.T) {
+	for _, format := range []LogFormat{ConsoleFormat, JsonFormat, LogfmtFormat} {
+		t.Run(string(format), func(t *testing.T) {
+			sink := &gatedSink{gate: make(chan struct{}), formatter: NewFormatter(format, nil)}
+			async := NewAsyncSink(sink, 8, BlockPolicy)
+			factory := NewLoggerFactory(Lowest, nil)
+			_ = factory.SetSinks(async)
+			logger := factory.CreateLogger("test")
+
+			values := map[string]int{"before": 1}
+			logger.With("values", values).Information("logged %v", values)
+			// the sink goroutine can't have read the entry yet, a race detector run fails if it still shares the map
+			values["after"] = 2
+			delete(values, "before")
+			close(sink.gate)
+			if err := async.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			_ = async.Close()
+
+			line := sink.String()
+			if !strings.Contains(line, "before") || strings.Contains(line, "after") {
+				t.Errorf("the line doesn't have the values of the call: %s", line)
+			}
+		})
+	}
+}
+
+func TestAsyncSinkKeepsTheJsonOfTheValues(t *testing.T) {
+	sink := &gatedSink{gate: make(chan struct{}), formatter: &JsonFormatter{}}
+	close(sink.gate)
+	async := NewAsyncSink(sink, 8, BlockPolicy)
+	err := async.Write(&Entry{
+		Time:     time.Now(),
+		Level:    Information,
+		Template: "%v",
+		Message:  "map[a:1]",
+		Args:     []interface{}{map[string]int{"a": 1}},
+		Fields:   []Field{{Key: "password", Value: Secret("hidden")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = async.Close()
+	line := sink.String()
+	if !strings.Contains(line, `"args":[{"a":1}]`) {
+		t.Errorf("the map argument is not a JSON object: %s", line)
+	}
+	if strings.Contains(line, "hidden") {
+		t.Errorf("the secret is revealed: %s", line)
+	}
+}
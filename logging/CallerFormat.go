@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CallerFormat tells how the source of a log entry is reported.
+type CallerFormat string
+
+const (
+	// CallerOff doesn't report the source, which also saves looking the caller up
+	CallerOff CallerFormat = "off"
+	// CallerShort reports the file name and line, e.g. "HttpService.go:239"
+	CallerShort CallerFormat = "short"
+	// CallerFunction reports the function and line, e.g. "services.(*HttpService).Run:239"
+	CallerFunction CallerFormat = "function"
+	// CallerFull reports the absolute file path and line, as built on the build machine
+	CallerFull CallerFormat = "full"
+)
+
+// ParseCallerFormat parses the name of a CallerFormat.
+func ParseCallerFormat(s string) (CallerFormat, error) {
+	switch CallerFormat(s) {
+	case CallerOff, CallerShort, CallerFunction, CallerFull:
+		return CallerFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown caller format: %s", s)
+	}
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (f *CallerFormat) UnmarshalJSON(bytes []byte) error {
+	var s string
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return err
+	}
+	format, err := ParseCallerFormat(s)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// setCaller fills the source of entry from the program counter pc according to format.
+func (f CallerFormat) setCaller(entry *Entry, pc uintptr) {
+	if f == CallerOff || pc == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	entry.Line = frame.Line
+	switch f {
+	case CallerFunction:
+		// drop the module path, "httpServer/services.(*HttpService).Run" is reported as "services.(*HttpService).Run"
+		entry.Function = frame.Function[strings.LastIndexByte(frame.Function, '/')+1:]
+	case CallerFull:
+		entry.File = frame.File
+	default:
+		entry.File = path.Base(frame.File)
+	}
+}
+
+// Source returns "file:line" or "function:line", empty when the entry has no source.
+func (e *Entry) Source() string {
+	switch {
+	case e.Function != "":
+		return e.Function + ":" + strconv.Itoa(e.Line)
+	case e.File != "":
+		return e.File + ":" + strconv.Itoa(e.Line)
+	default:
+		return ""
+	}
+}
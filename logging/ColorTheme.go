@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ColorTheme maps the levels to the ANSI escape sequences of the console lines, None is the sequence clearing the colour.
+type ColorTheme map[LogLevel]string
+
+// DefaultColorTheme returns the colours of DefaultColor.
+func DefaultColorTheme() ColorTheme {
+	theme := ColorTheme{}
+	for level := None; level <= Highest; level++ {
+		theme[level] = DefaultColor(level)
+	}
+	return theme
+}
+
+// NewColorTheme returns the DefaultColorTheme with the colours of overrides, keyed by level name, e.g. {"Warning": "bold yellow"}.
+// A colour is a name (black, red, green, yellow, blue, magenta, cyan, white or gray) optionally prefixed by "bold ",
+// or the raw SGR parameters such as "1;33".
+func NewColorTheme(overrides map[string]string) (ColorTheme, error) {
+	theme := DefaultColorTheme()
+	for name, color := range overrides {
+		level, err := ParseLogLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		sequence, err := parseColor(color)
+		if err != nil {
+			return nil, fmt.Errorf("color of %s: %w", name, err)
+		}
+		theme[level] = sequence
+	}
+	return theme, nil
+}
+
+// Color returns the escape sequence of level, it is used as ConsoleFormatter.ColorProvider.
+func (t ColorTheme) Color(level LogLevel) string {
+	if color, ok := t[level]; ok {
+		return color
+	}
+	return colorClear
+}
+
+var colorCodes = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"gray":    "90",
+}
+
+func parseColor(color string) (string, error) {
+	color = strings.TrimSpace(color)
+	if color != "" && strings.Trim(color, "0123456789;") == "" {
+		return "\033[" + color + "m", nil
+	}
+	weight := "0"
+	if name, ok := strings.CutPrefix(color, "bold "); ok {
+		weight = "1"
+		color = strings.TrimSpace(name)
+	}
+	code, ok := colorCodes[color]
+	if !ok {
+		return "", fmt.Errorf("unknown color: %q", color)
+	}
+	return "\033[" + weight + ";" + code + "m", nil
+}
+
+// DetectColors returns theme when the lines written to writer should be coloured, nil otherwise.
+// NO_COLOR disables the colours and FORCE_COLOR enables them, see https://no-color.org,
+// otherwise only the terminals are coloured so files and container logs don't get escape sequences.
+func DetectColors(writer io.Writer, theme ColorTheme) ColorTheme {
+	if shouldColor(writer) {
+		return theme
+	}
+	return nil
+}
+
+func shouldColor(writer io.Writer) bool {
+	if value, ok := os.LookupEnv("NO_COLOR"); ok && value != "" {
+		return false
+	}
+	if value, ok := os.LookupEnv("FORCE_COLOR"); ok {
+		return value != "0" && value != "false"
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	file, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setColorEnvironment sets the variables read by shouldColor to env and unsets the others, until the end of the test.
+func setColorEnvironment(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, key := range []string{"NO_COLOR", "FORCE_COLOR", "TERM"} {
+		// Setenv restores the variable at the end of the test, even when it is unset below
+		t.Setenv(key, "")
+		if value, ok := env[key]; ok {
+			_ = os.Setenv(key, value)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	}
+}
+
+func TestShouldColor(t *testing.T) {
+	// /dev/null is a character device like the terminals
+	device, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer device.Close()
+	file, err := os.Create(filepath.Join(t.TempDir(), "server.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		writer   io.Writer
+		expected bool
+	}{
+		{"terminal", nil, device, true},
+		{"buffer", nil, &bytes.Buffer{}, false},
+		{"file", nil, file, false},
+		{"dumb terminal", map[string]string{"TERM": "dumb"}, device, false},
+		{"other terminal", map[string]string{"TERM": "xterm-256color"}, device, true},
+		{"no color", map[string]string{"NO_COLOR": "1"}, device, false},
+		{"empty no color", map[string]string{"NO_COLOR": ""}, device, true},
+		{"forced on a buffer", map[string]string{"FORCE_COLOR": "1"}, &bytes.Buffer{}, true},
+		{"forced on a dumb terminal", map[string]string{"FORCE_COLOR": "true", "TERM": "dumb"}, file, true},
+		{"forced off", map[string]string{"FORCE_COLOR": "0"}, device, false},
+		{"forced false", map[string]string{"FORCE_COLOR": "false"}, device, false},
+		{"no color wins over forced", map[string]string{"NO_COLOR": "1", "FORCE_COLOR": "1"}, &bytes.Buffer{}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setColorEnvironment(t, test.env)
+			if colored := shouldColor(test.writer); colored != test.expected {
+				t.Errorf("got %t", colored)
+			}
+			theme := DefaultColorTheme()
+			if detected := DetectColors(test.writer, theme); (detected != nil) != test.expected {
+				t.Errorf("DetectColors returns %v", detected)
+			}
+		})
+	}
+}
+
+func TestNewColorTheme(t *testing.T) {
+	tests := []struct {
+		name     string
+		color    string
+		expected string
+	}{
+		{"name", "red", "\033[0;31m"},
+		{"bold name", "bold yellow", "\033[1;33m"},
+		{"spaces", "  bold   gray ", "\033[1;90m"},
+		{"raw sgr", "1;38;5;208", "\033[1;38;5;208m"},
+		{"raw reset", "0", "\033[0m"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			theme, err := NewColorTheme(map[string]string{"Warning": test.color})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if color := theme.Color(Warning); color != test.expected {
+				t.Errorf("got %q, want %q", color, test.expected)
+			}
+			if theme.Color(Error) != DefaultColor(Error) || theme.Color(None) != DefaultColor(None) {
+				t.Error("the other levels don't keep their default colour")
+			}
+		})
+	}
+}
+
+func TestNewColorThemeRejectsUnknownNames(t *testing.T) {
+	for _, overrides := range []map[string]string{
+		{"Loud": "red"},
+		{"warning": "red"},
+		{"Warning": "pink"},
+		{"Warning": "bold"},
+		{"Warning": "bold 31"},
+		{"Warning": ""},
+		{"Warning": "\033[31m"},
+	} {
+		if theme, err := NewColorTheme(overrides); err == nil {
+			t.Errorf("%q is accepted: %q", overrides, theme)
+		}
+	}
+}
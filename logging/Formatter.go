@@ -0,0 +1,236 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a single log message, built by the logger and rendered by an IFormatter.
+type Entry struct {
+	Time  time.Time
+	Level LogLevel
+	// File or Function, and Line are the source of the message, empty when the caller is not reported, see CallerFormat
+	File     string
+	Function string
+	Line     int
+	// Category is the category of the logger, empty for the root logger
+	Category string
+	// Template is the format string given to the logger, Message is the template rendered with Args
+	Template string
+	Message  string
+	Args     []interface{}
+	// Fields are the structured fields added by ILogger.With
+	Fields []Field
+}
+
+// IFormatter renders an Entry into a single line, including the trailing newline.
+type IFormatter interface {
+	Format(entry *Entry) []byte
+}
+
+// LogFormat selects the IFormatter of the log output.
+type LogFormat string
+
+const (
+	// ConsoleFormat is the human-readable line, coloured on terminals
+	ConsoleFormat LogFormat = "console"
+	// JsonFormat is a JSON object per line
+	JsonFormat LogFormat = "json"
+	// LogfmtFormat is a line of key=value pairs
+	LogfmtFormat LogFormat = "logfmt"
+)
+
+// ParseLogFormat parses the name of a LogFormat.
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch LogFormat(s) {
+	case ConsoleFormat, JsonFormat, LogfmtFormat:
+		return LogFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown log format: %s", s)
+	}
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (f *LogFormat) UnmarshalJSON(bytes []byte) error {
+	var s string
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return err
+	}
+	format, err := ParseLogFormat(s)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// NewFormatter returns the IFormatter of format, the console formatter for unknown formats.
+// theme colours the console lines, nil disables the colours, see DetectColors.
+func NewFormatter(format LogFormat, theme ColorTheme) IFormatter {
+	switch format {
+	case JsonFormat:
+		return &JsonFormatter{}
+	case LogfmtFormat:
+		return &LogfmtFormatter{}
+	default:
+		formatter := &ConsoleFormatter{}
+		if theme != nil {
+			formatter.ColorProvider = theme.Color
+		}
+		return formatter
+	}
+}
+
+const timeLayout = "2006-01-02 15:04:05.000"
+
+// ConsoleFormatter renders `[2025-04-15 15:13:45.000 D] [main.go:15] api.PerlinNoise: message {requestId=1a2b}`.
+type ConsoleFormatter struct {
+	// ColorProvider returns the escape sequence of a level, nil disables the colours
+	ColorProvider func(LogLevel) string
+}
+
+func (f *ConsoleFormatter) Format(entry *Entry) []byte {
+	builder := strings.Builder{}
+	if f.ColorProvider != nil {
+		builder.WriteString(f.ColorProvider(entry.Level))
+	}
+	builder.WriteRune('[')
+	builder.WriteString(entry.Time.Format(timeLayout))
+	builder.WriteRune(' ')
+	builder.WriteByte(entry.Level.String()[0])
+	builder.WriteRune(']')
+	if source := entry.Source(); source != "" {
+		builder.WriteString(" [")
+		builder.WriteString(source)
+		builder.WriteRune(']')
+	}
+	if entry.Category != "" {
+		builder.WriteRune(' ')
+		builder.WriteString(entry.Category)
+	}
+	builder.WriteString(": ")
+	builder.WriteString(entry.Message)
+	if len(entry.Fields) > 0 {
+		builder.WriteString(" {")
+		for i, field := range entry.Fields {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			builder.WriteString(field.Key)
+			builder.WriteRune('=')
+			builder.WriteString(fmt.Sprint(field.Value))
+		}
+		builder.WriteRune('}')
+	}
+	if f.ColorProvider != nil {
+		builder.WriteString(f.ColorProvider(None))
+	}
+	builder.WriteRune('\n')
+	return []byte(builder.String())
+}
+
+// JsonFormatter renders an object per line, the fields are nested in "fields" so they can't override the entry keys:
+// {"time":"2025-04-15T15:13:45.000Z","level":"Debug","source":"main.go:15","category":"api","template":"got %d","message":"got 1","args":[1],"fields":{"requestId":"1a2b"}}
+type JsonFormatter struct{}
+
+type jsonEntry struct {
+	Time     string                 `json:"time"`
+	Level    string                 `json:"level"`
+	Source   string                 `json:"source,omitempty"`
+	Category string                 `json:"category,omitempty"`
+	Template string                 `json:"template"`
+	Message  string                 `json:"message"`
+	Args     []interface{}          `json:"args,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+func (f *JsonFormatter) Format(entry *Entry) []byte {
+	object := jsonEntry{
+		Time:     entry.Time.Format(time.RFC3339Nano),
+		Level:    entry.Level.String(),
+		Source:   entry.Source(),
+		Category: entry.Category,
+		Template: entry.Template,
+		Message:  entry.Message,
+	}
+	if len(entry.Args) > 0 {
+		object.Args = make([]interface{}, len(entry.Args))
+		for i, arg := range entry.Args {
+			object.Args[i] = jsonValue(arg)
+		}
+	}
+	if len(entry.Fields) > 0 {
+		object.Fields = make(map[string]interface{}, len(entry.Fields))
+		for _, field := range entry.Fields {
+			object.Fields[field.Key] = jsonValue(field.Value)
+		}
+	}
+	line, err := json.Marshal(object)
+	if err != nil {
+		// jsonValue only keeps marshalable values, this is not expected
+		line, _ = json.Marshal(jsonEntry{Time: object.Time, Level: object.Level, Template: entry.Template, Message: entry.Message})
+	}
+	return append(line, '\n')
+}
+
+// jsonValue keeps the values encoding/json can marshal, errors and the other values are written as their fmt.Sprint text.
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value
+	case renderedValue:
+		// already rendered by an AsyncSink, its JSON is kept
+		return value
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	}
+	_, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return value
+}
+
+// LogfmtFormatter renders key=value pairs, the arguments are arg0, arg1... and the fields follow with their own keys:
+// time=2025-04-15T15:13:45.000Z level=Debug source=main.go:15 category=api template="got %d" message="got 1" arg0=1 requestId=1a2b
+type LogfmtFormatter struct{}
+
+func (f *LogfmtFormatter) Format(entry *Entry) []byte {
+	builder := strings.Builder{}
+	writeLogfmtPair(&builder, "time", entry.Time.Format(time.RFC3339Nano))
+	writeLogfmtPair(&builder, "level", entry.Level.String())
+	if source := entry.Source(); source != "" {
+		writeLogfmtPair(&builder, "source", source)
+	}
+	if entry.Category != "" {
+		writeLogfmtPair(&builder, "category", entry.Category)
+	}
+	writeLogfmtPair(&builder, "template", entry.Template)
+	writeLogfmtPair(&builder, "message", entry.Message)
+	for i, arg := range entry.Args {
+		writeLogfmtPair(&builder, "arg"+strconv.Itoa(i), fmt.Sprint(arg))
+	}
+	for _, field := range entry.Fields {
+		writeLogfmtPair(&builder, field.Key, fmt.Sprint(field.Value))
+	}
+	builder.WriteRune('\n')
+	return []byte(builder.String())
+}
+
+func writeLogfmtPair(builder *strings.Builder, key string, value string) {
+	if builder.Len() > 0 {
+		builder.WriteRune(' ')
+	}
+	builder.WriteString(key)
+	builder.WriteRune('=')
+	if value == "" || strings.ContainsAny(value, " =\"\\") || strings.ContainsFunc(value, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		builder.WriteString(strconv.Quote(value))
+		return
+	}
+	builder.WriteString(value)
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formattedPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// newFormattedEntry returns an entry with arguments and fields of every kind handled by the formatters.
+func newFormattedEntry() *Entry {
+	return &Entry{
+		Time:     time.Date(2025, 4, 15, 15, 13, 45, 123000000, time.UTC),
+		Level:    Debug,
+		File:     "main.go",
+		Line:     15,
+		Category: "api",
+		Template: "got %d from %s",
+		Message:  "got 1 from a \"b\"\n<c>",
+		Args:     []interface{}{1, "a \"b\"\n<c>"},
+		Fields: []Field{
+			{Key: "requestId", Value: "1a2b"},
+			{Key: "error", Value: errors.New(`bad "x"`)},
+			{Key: "elapsed", Value: 1500 * time.Millisecond},
+			{Key: "point", Value: formattedPoint{X: 1, Y: 2}},
+			{Key: "complex", Value: 1 + 2i},
+			{Key: "empty", Value: ""},
+			{Key: "message", Value: "not the message"},
+		},
+	}
+}
+
+func TestJsonFormatter(t *testing.T) {
+	line := string((&JsonFormatter{}).Format(newFormattedEntry()))
+	expected := `{"time":"2025-04-15T15:13:45.123Z","level":"Debug","source":"main.go:15","category":"api","template":"got %d from %s",` +
+		`"message":"got 1 from a \"b\"\n\u003cc\u003e","args":[1,"a \"b\"\n\u003cc\u003e"],` +
+		`"fields":{"complex":"(1+2i)","elapsed":"1.5s","empty":"","error":"bad \"x\"","message":"not the message","point":{"x":1,"y":2},"requestId":"1a2b"}}` + "\n"
+	if line != expected {
+		t.Errorf("got\n%s\nwant\n%s", line, expected)
+	}
+}
+
+func TestJsonFormatterOmitsTheEmptyParts(t *testing.T) {
+	line := (&JsonFormatter{}).Format(&Entry{Time: time.Date(2025, 4, 15, 15, 13, 45, 0, time.UTC), Level: Warning, Template: "done", Message: "done"})
+	var object map[string]any
+	if err := json.Unmarshal(line, &object); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"time": "2025-04-15T15:13:45Z", "level": "Warning", "template": "done", "message": "done"}
+	if len(object) != len(expected) {
+		t.Errorf("got %s", line)
+	}
+	for key, value := range expected {
+		if object[key] != value {
+			t.Errorf("%s is %v, want %v", key, object[key], value)
+		}
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	line := string((&LogfmtFormatter{}).Format(newFormattedEntry()))
+	expected := `time=2025-04-15T15:13:45.123Z level=Debug source=main.go:15 category=api template="got %d from %s" ` +
+		`message="got 1 from a \"b\"\n<c>" arg0=1 arg1="a \"b\"\n<c>" ` +
+		`requestId=1a2b error="bad \"x\"" elapsed=1.5s point="{1 2}" complex=(1+2i) empty="" message="not the message"` + "\n"
+	if line != expected {
+		t.Errorf("got\n%s\nwant\n%s", line, expected)
+	}
+}
+
+func TestLogfmtQuoting(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"plain", `k=plain`},
+		{"", `k=""`},
+		{"a b", `k="a b"`},
+		{"a=b", `k="a=b"`},
+		{`a"b`, `k="a\"b"`},
+		{`a\b`, `k="a\\b"`},
+		{"a\tb", `k="a\tb"`},
+		{"a\x7fb", `k="a\x7fb"`},
+		{"é/ü:1", `k=é/ü:1`},
+	}
+	for _, test := range tests {
+		builder := strings.Builder{}
+		writeLogfmtPair(&builder, "k", test.value)
+		if builder.String() != test.expected {
+			t.Errorf("%q: got %s, want %s", test.value, builder.String(), test.expected)
+		}
+	}
+}
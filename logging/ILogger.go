@@ -18,29 +18,38 @@ func (l *LogLevel) UnmarshalJSON(bytes []byte) error {
 	if err := json.Unmarshal(bytes, &s); err != nil {
 		return err
 	}
+	level, err := ParseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// ParseLogLevel parses the name of a LogLevel, as returned by LogLevel.String.
+func ParseLogLevel(s string) (LogLevel, error) {
 	switch s {
 	case "Lowest":
-		*l = Lowest
+		return Lowest, nil
 	case "Trace":
-		*l = Trace
+		return Trace, nil
 	case "Verbose":
-		*l = Verbose
+		return Verbose, nil
 	case "Debug":
-		*l = Debug
+		return Debug, nil
 	case "Information":
-		*l = Information
+		return Information, nil
 	case "Warning":
-		*l = Warning
+		return Warning, nil
 	case "Error":
-		*l = Error
+		return Error, nil
 	case "Fatal":
-		*l = Fatal
+		return Fatal, nil
 	case "Highest":
-		*l = Highest
+		return Highest, nil
 	default:
-		return fmt.Errorf("unknown log level: %s", s)
+		return None, fmt.Errorf("unknown log level: %s", s)
 	}
-	return nil
 }
 
 const (
@@ -74,9 +83,24 @@ type ILogger interface {
 	Warning(msg string, args ...interface{})
 	Error(msg string, args ...interface{})
 	Fatal(msg string, args ...interface{})
+	// Log logs msg at level, skip is the number of stack frames above Log to reach the reported caller as for runtime.Caller:
+	// 1 is the function calling Log, the level methods use 2. Adapters add one to skip for every frame of their own between the caller and Log.
 	Log(level LogLevel, msg string, skip int, args ...interface{})
 
 	LogLevel() LogLevel
+	// SetLogLevel changes the minimum log level, it is safe to call while logging
+	SetLogLevel(level LogLevel)
+	CallerFormat() CallerFormat
+	// SetCallerFormat changes how the caller is reported by this logger, it is safe to call while logging
+	SetCallerFormat(format CallerFormat)
+	// With returns a logger printing the field key=value with every message, the current logger is not modified
+	With(key string, value interface{}) ILogger
+}
+
+// Field is a structured key value attached to a logger by ILogger.With.
+type Field struct {
+	Key   string
+	Value interface{}
 }
 
 //goland:noinspection GoMixedReceiverTypes
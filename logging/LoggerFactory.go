@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ILoggerFactory creates loggers named after a category, usually "package.Type" such as "api.PerlinNoise".
+type ILoggerFactory interface {
+	CreateLogger(category string) ILogger
+}
+
+// LoggerFactory creates loggers sharing the same minimum levels and sinks, which can be changed while logging.
+type LoggerFactory struct {
+	levels       *categoryLevels
+	output       *output
+	callerFormat *atomic.Pointer[CallerFormat]
+}
+
+// NewLoggerFactory creates a factory writing console lines to stdout until SetSinks is called, where levels overrides defaultLevel
+// for the categories and their sub categories, e.g. "api" applies to "api.PerlinNoise" unless "api.PerlinNoise" has its own level.
+func NewLoggerFactory(defaultLevel LogLevel, levels map[string]LogLevel) *LoggerFactory {
+	factory := &LoggerFactory{
+		levels:       newCategoryLevels(defaultLevel),
+		output:       newOutput(NewWriterSink(os.Stdout, Lowest, NewFormatter(ConsoleFormat, DetectColors(os.Stdout, DefaultColorTheme())))),
+		callerFormat: newDefaultCallerFormat(),
+	}
+	factory.SetLevels(defaultLevel, levels)
+	return factory
+}
+
+func (f *LoggerFactory) CreateLogger(category string) ILogger {
+	return newLogger(category, f.levels, f.output, f.callerFormat)
+}
+
+// SetCallerFormat sets the caller format of the loggers created by the factory, except the ones with their own ILogger.SetCallerFormat.
+func (f *LoggerFactory) SetCallerFormat(format CallerFormat) {
+	f.callerFormat.Store(&format)
+}
+
+// SetSinks replaces the sinks of every logger created by the factory, the replaced sinks are closed.
+func (f *LoggerFactory) SetSinks(sinks ...ISink) error {
+	return closeSinks(f.output.setSinks(sinks))
+}
+
+// SetRedactor replaces the Redactor masking the credentials, nil disables the redaction.
+func (f *LoggerFactory) SetRedactor(redactor *Redactor) {
+	f.output.setRedactor(redactor)
+}
+
+// Flush waits for the buffered entries of the sinks to be written.
+func (f *LoggerFactory) Flush() error {
+	return f.output.flush()
+}
+
+// Close closes the sinks, the loggers must not be used anymore.
+func (f *LoggerFactory) Close() error {
+	return closeSinks(f.output.setSinks(nil))
+}
+
+// SetLevels replaces the default level and all the category levels.
+func (f *LoggerFactory) SetLevels(defaultLevel LogLevel, levels map[string]LogLevel) {
+	f.levels.replace(defaultLevel, levels)
+}
+
+// SetLevel sets the level of category, the empty category is the default level.
+func (f *LoggerFactory) SetLevel(category string, level LogLevel) {
+	f.levels.set(category, level)
+}
+
+// RemoveLevel removes the level of category, so it uses the level of its parent category again.
+func (f *LoggerFactory) RemoveLevel(category string) {
+	f.levels.remove(category)
+}
+
+// Levels returns a copy of the default level and the category levels.
+func (f *LoggerFactory) Levels() (LogLevel, map[string]LogLevel) {
+	table := f.levels.current.Load()
+	return table.defaultLevel, maps.Clone(table.categories)
+}
+
+// categoryLevels are the minimum levels shared by the loggers of a factory.
+// Readers load the current table without locking, writers replace it.
+type categoryLevels struct {
+	current atomic.Pointer[levelTable]
+	mutex   sync.Mutex
+}
+
+type levelTable struct {
+	defaultLevel LogLevel
+	categories   map[string]LogLevel
+}
+
+func newCategoryLevels(defaultLevel LogLevel) *categoryLevels {
+	levels := &categoryLevels{}
+	levels.replace(defaultLevel, nil)
+	return levels
+}
+
+// levelOf returns the level of the closest configured category, walking up the dot separated parents.
+func (c *categoryLevels) levelOf(category string) LogLevel {
+	table := c.current.Load()
+	for category != "" {
+		if level, ok := table.categories[category]; ok {
+			return level
+		}
+		index := strings.LastIndexByte(category, '.')
+		if index < 0 {
+			break
+		}
+		category = category[:index]
+	}
+	return table.defaultLevel
+}
+
+func (c *categoryLevels) replace(defaultLevel LogLevel, categories map[string]LogLevel) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	categories = maps.Clone(categories)
+	if categories == nil {
+		categories = make(map[string]LogLevel)
+	}
+	c.current.Store(&levelTable{defaultLevel: defaultLevel, categories: categories})
+}
+
+func (c *categoryLevels) set(category string, level LogLevel) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	table := c.current.Load()
+	categories := maps.Clone(table.categories)
+	defaultLevel := table.defaultLevel
+	if category == "" {
+		defaultLevel = level
+	} else {
+		categories[category] = level
+	}
+	c.current.Store(&levelTable{defaultLevel: defaultLevel, categories: categories})
+}
+
+func (c *categoryLevels) remove(category string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	table := c.current.Load()
+	categories := maps.Clone(table.categories)
+	delete(categories, category)
+	c.current.Store(&levelTable{defaultLevel: table.defaultLevel, categories: categories})
+}
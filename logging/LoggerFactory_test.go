@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"maps"
+	"testing"
+)
+
+// expectLevels checks the level of every category of expected, through a logger created before the changes when there is one.
+func expectLevels(t *testing.T, factory *LoggerFactory, loggers map[string]ILogger, expected map[string]LogLevel) {
+	t.Helper()
+	for category, level := range expected {
+		logger, ok := loggers[category]
+		if !ok {
+			logger = factory.CreateLogger(category)
+		}
+		if logger.LogLevel() != level {
+			t.Errorf("%q is on level %s, want %s", category, logger.LogLevel(), level)
+		}
+	}
+}
+
+func TestCategoryLevelsAreInherited(t *testing.T) {
+	factory := NewLoggerFactory(Warning, map[string]LogLevel{"api": Debug, "api.PerlinNoise": Trace, "db.Query": Error})
+	expectLevels(t, factory, nil, map[string]LogLevel{
+		"":                       Warning,
+		"api":                    Debug,
+		"api.DrunkBishop":        Debug,
+		"api.PerlinNoise":        Trace,
+		"api.PerlinNoise.Octave": Trace,
+		"apiserver":              Warning,
+		"db":                     Warning,
+		"db.Query.Slow":          Error,
+		"db.QueryBuilder":        Warning,
+	})
+
+	sink := &recordingSink{}
+	_ = factory.SetSinks(sink)
+	logger := factory.CreateLogger("api.DrunkBishop").With("requestId", "1a2b")
+	logger.Verbose("hidden")
+	logger.Debug("shown")
+	if len(sink.entries) != 1 || sink.entries[0].Message != "shown" || sink.entries[0].Category != "api.DrunkBishop" {
+		t.Errorf("got %+v", sink.entries)
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	levels := map[string]LogLevel{"api": Debug}
+	factory := NewLoggerFactory(Warning, levels)
+	levels["api"] = Fatal
+	levels["db"] = Fatal
+	if _, current := factory.Levels(); len(current) != 1 || current["api"] != Debug {
+		t.Errorf("the map given to the factory is shared: %v", current)
+	}
+	loggers := map[string]ILogger{}
+	for _, category := range []string{"", "api", "api.PerlinNoise", "db.Query"} {
+		loggers[category] = factory.CreateLogger(category).With("a", 1)
+	}
+
+	factory.SetLevels(Error, map[string]LogLevel{"db": Trace})
+	expectLevels(t, factory, loggers, map[string]LogLevel{"": Error, "api": Error, "api.PerlinNoise": Error, "db.Query": Trace})
+
+	factory.SetLevel("api", Verbose)
+	factory.SetLevel("", Debug)
+	expectLevels(t, factory, loggers, map[string]LogLevel{"": Debug, "api": Verbose, "api.PerlinNoise": Verbose, "db.Query": Trace})
+
+	loggers["api.PerlinNoise"].SetLogLevel(Information)
+	expectLevels(t, factory, loggers, map[string]LogLevel{"api": Verbose, "api.PerlinNoise": Information, "api.PerlinNoise.Octave": Information})
+
+	factory.RemoveLevel("api")
+	factory.RemoveLevel("unknown")
+	expectLevels(t, factory, loggers, map[string]LogLevel{"api": Debug, "api.PerlinNoise": Information, "db.Query": Trace})
+
+	// the default level is not a category, it can't be removed
+	factory.RemoveLevel("")
+	defaultLevel, current := factory.Levels()
+	if defaultLevel != Debug || !maps.Equal(current, map[string]LogLevel{"api.PerlinNoise": Information, "db": Trace}) {
+		t.Errorf("got %s, %v", defaultLevel, current)
+	}
+	current["db"] = Fatal
+	if _, again := factory.Levels(); again["db"] != Trace {
+		t.Error("Levels returns the map of the factory")
+	}
+}
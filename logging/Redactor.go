@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redacted replaces the masked values in the log entries.
+const Redacted = "[REDACTED]"
+
+// Secret is a string which is masked when formatted, so it can be passed to a logger or kept in a logged struct.
+// Use Reveal, or a conversion to string, where the value is really needed.
+type Secret string
+
+func (s Secret) String() string {
+	return Redacted
+}
+
+func (s Secret) GoString() string {
+	return Redacted
+}
+
+// Format masks the secret for every verb, including %x and %q.
+func (s Secret) Format(f fmt.State, verb rune) {
+	_, _ = fmt.Fprint(f, Redacted)
+}
+
+// MarshalJSON masks the secret in the JSON lines, where a logged struct holding it goes through encoding/json.
+// Reveal the value explicitly to write it to a file.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + Redacted + `"`), nil
+}
+
+// Reveal returns the secret value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// DefaultRedactionKeys are the field keys whose values are always masked, matched case-insensitively as path.Match patterns.
+var DefaultRedactionKeys = []string{"*password*", "*secret*", "*token*", "authorization", "proxy-authorization", "cookie", "set-cookie", "*apikey*", "*api_key*", "*api-key*"}
+
+// DefaultRedactionPatterns are the credentials scrubbed from the messages, the arguments and the field values:
+// the HTTP authorization schemes and the JWTs.
+var DefaultRedactionPatterns = []string{
+	`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`,
+	`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`,
+}
+
+// Redactor masks the credentials of the log entries before they are written to the sinks.
+type Redactor struct {
+	keys     []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor returns a Redactor masking the DefaultRedactionKeys and DefaultRedactionPatterns, and the extra ones.
+// The text matched by a pattern is replaced by Redacted, or by its first group followed by Redacted when it has one,
+// so "Bearer abc" becomes "Bearer [REDACTED]".
+func NewRedactor(keys []string, patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, key := range append(slices.Clone(DefaultRedactionKeys), keys...) {
+		key = strings.ToLower(key)
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("redaction key %q: %w", key, err)
+		}
+		r.keys = append(r.keys, key)
+	}
+	for _, pattern := range append(slices.Clone(DefaultRedactionPatterns), patterns...) {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, compiled)
+	}
+	return r, nil
+}
+
+// DefaultRedactor returns the Redactor of the DefaultRedactionKeys and DefaultRedactionPatterns.
+func DefaultRedactor() *Redactor {
+	r, err := NewRedactor(nil, nil)
+	if err != nil {
+		// nothing is added to the defaults, only a broken DefaultRedactionKeys or DefaultRedactionPatterns entry gets here
+		panic(err)
+	}
+	return r
+}
+
+// Redact returns a copy of entry with the credentials masked, entry itself is not modified.
+func (r *Redactor) Redact(entry *Entry) *Entry {
+	redacted := *entry
+	// the JSON and logfmt lines also write the template, where a credential can be written without arguments
+	redacted.Template = r.Scrub(entry.Template)
+	redacted.Message = r.Scrub(entry.Message)
+	if len(entry.Args) > 0 {
+		redacted.Args = make([]interface{}, len(entry.Args))
+		for i, arg := range entry.Args {
+			redacted.Args[i] = r.scrubValue(arg)
+		}
+	}
+	if len(entry.Fields) > 0 {
+		redacted.Fields = make([]Field, len(entry.Fields))
+		for i, field := range entry.Fields {
+			if r.IsSecretKey(field.Key) {
+				redacted.Fields[i] = Field{Key: field.Key, Value: Redacted}
+				continue
+			}
+			redacted.Fields[i] = Field{Key: field.Key, Value: r.scrubValue(field.Value)}
+		}
+	}
+	return &redacted
+}
+
+// IsSecretKey reports whether the values of key are masked, e.g. the "Authorization" header.
+func (r *Redactor) IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r.keys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Scrub masks the text matched by the patterns.
+func (r *Redactor) Scrub(text string) string {
+	for _, pattern := range r.patterns {
+		if pattern.NumSubexp() > 0 {
+			text = pattern.ReplaceAllString(text, "${1} "+Redacted)
+		} else {
+			text = pattern.ReplaceAllString(text, Redacted)
+		}
+	}
+	return text
+}
+
+// scrubValue keeps value when its text has nothing to mask, so the formatters still get the original type.
+func (r *Redactor) scrubValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case nil, Secret, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value
+	case string:
+		return r.Scrub(value)
+	}
+	text := fmt.Sprint(value)
+	if scrubbed := r.Scrub(text); scrubbed != text {
+		return scrubbed
+	}
+	return value
+}
@@ -0,0 +1,274 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFileOptions tells when a RotatingFileSink rotates its file and how long the rotated files are kept.
+type RotatingFileOptions struct {
+	// MaxSize rotates the file before it grows over MaxSize bytes, 0 disables the size rotation
+	MaxSize int64
+	// Interval rotates the file once it has been written for Interval since it was opened, 0 disables the time rotation
+	Interval time.Duration
+	// MaxBackups is the number of rotated files kept, 0 keeps all of them
+	MaxBackups int
+	// MaxAge removes the rotated files older than MaxAge, 0 keeps all of them
+	MaxAge time.Duration
+	// Compress gzips the rotated files
+	Compress bool
+}
+
+// backupTimeLayout is appended to the file name of the rotated files, e.g. "server.log.20250415-151345.000".
+// The rotations of the same millisecond get a sequence number, e.g. "server.log.20250415-151345.000-1".
+const backupTimeLayout = "20060102-150405.000"
+
+// RotatingFileSink writes the formatted entries to a file, which is renamed with the rotation time once it is too large or too old.
+// Compressing and removing the rotated files is done in the background.
+type RotatingFileSink struct {
+	path      string
+	level     LogLevel
+	formatter IFormatter
+	options   RotatingFileOptions
+
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// maintenance serializes the compression and removal of the rotated files, Close waits for it
+	maintenance sync.Mutex
+	pending     sync.WaitGroup
+}
+
+// NewRotatingFileSink opens path for appending, creating it and its directory when needed.
+func NewRotatingFileSink(path string, level LogLevel, formatter IFormatter, options RotatingFileOptions) (*RotatingFileSink, error) {
+	sink := &RotatingFileSink{
+		path:      path,
+		level:     level,
+		formatter: formatter,
+		options:   options,
+	}
+	err := sink.open()
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (s *RotatingFileSink) Level() LogLevel {
+	return s.level
+}
+
+func (s *RotatingFileSink) Write(entry *Entry) error {
+	line := s.formatter.Format(entry)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("log file %s is closed", s.path)
+	}
+	if s.shouldRotate(entry.Time, int64(len(line))) {
+		err := s.rotate(entry.Time)
+		if err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the file and waits for the background compression and removal.
+func (s *RotatingFileSink) Close() error {
+	s.mutex.Lock()
+	var err error
+	if s.file != nil {
+		err = s.file.Close()
+		s.file = nil
+	}
+	s.mutex.Unlock()
+	s.pending.Wait()
+	return err
+}
+
+func (s *RotatingFileSink) open() error {
+	err := os.MkdirAll(filepath.Dir(s.path), 0o755)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+// shouldRotate reports whether writing length bytes at now needs a new file, an empty file is never rotated.
+func (s *RotatingFileSink) shouldRotate(now time.Time, length int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.options.MaxSize > 0 && s.size+length > s.options.MaxSize {
+		return true
+	}
+	return s.options.Interval > 0 && now.Sub(s.openedAt) >= s.options.Interval
+}
+
+func (s *RotatingFileSink) rotate(now time.Time) error {
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return err
+	}
+	backup := s.backupName(now)
+	err = os.Rename(s.path, backup)
+	if err != nil {
+		// keep writing to the current file rather than losing the entries
+		return errors.Join(err, s.open())
+	}
+	err = s.open()
+	if err != nil {
+		return err
+	}
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		s.maintain(backup, now)
+	}()
+	return nil
+}
+
+// backupName returns the name of the file rotated at now, which must not replace a backup of the same millisecond.
+func (s *RotatingFileSink) backupName(now time.Time) string {
+	stamped := s.path + "." + now.Format(backupTimeLayout)
+	backup := stamped
+	for sequence := 1; fileExists(backup) || fileExists(backup+".gz"); sequence++ {
+		backup = stamped + "-" + strconv.Itoa(sequence)
+	}
+	return backup
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// maintain compresses the backup just rotated and removes the rotated files exceeding MaxBackups or MaxAge.
+func (s *RotatingFileSink) maintain(backup string, now time.Time) {
+	s.maintenance.Lock()
+	defer s.maintenance.Unlock()
+	if s.options.Compress {
+		err := compressFile(backup)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error compressing log file %s: %v\n", backup, err)
+		}
+	}
+	if s.options.MaxBackups <= 0 && s.options.MaxAge <= 0 {
+		return
+	}
+	backups, err := s.backups()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error listing rotated log files of %s: %v\n", s.path, err)
+		return
+	}
+	for i, backup := range backups {
+		if (s.options.MaxBackups > 0 && i >= s.options.MaxBackups) || (s.options.MaxAge > 0 && now.Sub(backup.rotatedAt) > s.options.MaxAge) {
+			err := os.Remove(backup.path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				_, _ = fmt.Fprintf(os.Stderr, "Error removing log file %s: %v\n", backup.path, err)
+			}
+		}
+	}
+}
+
+type rotatedFile struct {
+	path      string
+	rotatedAt time.Time
+	// sequence orders the files rotated in the same millisecond
+	sequence int
+}
+
+// backups returns the rotated files of the sink, the newest first.
+func (s *RotatingFileSink) backups() ([]rotatedFile, error) {
+	entries, err := os.ReadDir(filepath.Dir(s.path))
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(s.path) + "."
+	var backups []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		suffix := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if len(suffix) < len(backupTimeLayout) {
+			continue
+		}
+		rotatedAt, err := time.ParseInLocation(backupTimeLayout, suffix[:len(backupTimeLayout)], time.Local)
+		if err != nil {
+			continue
+		}
+		sequence := 0
+		if suffix = suffix[len(backupTimeLayout):]; suffix != "" {
+			sequence, err = strconv.Atoi(strings.TrimPrefix(suffix, "-"))
+			if err != nil || suffix[0] != '-' || sequence <= 0 {
+				continue
+			}
+		}
+		backups = append(backups, rotatedFile{path: filepath.Join(filepath.Dir(s.path), name), rotatedAt: rotatedAt, sequence: sequence})
+	}
+	slices.SortFunc(backups, func(a, b rotatedFile) int {
+		if order := b.rotatedAt.Compare(a.rotatedAt); order != 0 {
+			return order
+		}
+		return b.sequence - a.sequence
+	})
+	return backups, nil
+}
+
+// compressFile replaces path with path.gz.
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	_, err = io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = target.Close()
+	} else {
+		_ = target.Close()
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	_ = source.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// messageFormatter writes the message of the entries, one per line.
+type messageFormatter struct{}
+
+func (messageFormatter) Format(entry *Entry) []byte {
+	return []byte(entry.Message + "\n")
+}
+
+func TestRotationsOfTheSameMillisecondKeepEveryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	sink, err := NewRotatingFileSink(path, Lowest, messageFormatter{}, RotatingFileOptions{MaxSize: 4, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, message := range []string{"one", "two", "six", "ten"} {
+		// every line fills the file, so each write rotates the previous one at the same time
+		if err = sink.Write(&Entry{Time: now, Message: message}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stamped := path + "." + now.Format(backupTimeLayout)
+	expected := map[string]string{path: "ten\n", stamped + "-1": "two\n", stamped + "-2": "six\n"}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(expected) {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("got the files %s", strings.Join(names, ", "))
+	}
+	for file, content := range expected {
+		data, err := os.ReadFile(file)
+		if err != nil || string(data) != content {
+			t.Errorf("%s has %q, %v, want %q", filepath.Base(file), data, err, content)
+		}
+	}
+}
+
+func TestBackupsAreSortedBySequence(t *testing.T) {
+	dir := t.TempDir()
+	sink := &RotatingFileSink{path: filepath.Join(dir, "server.log")}
+	for _, name := range []string{
+		"server.log.20250415-151345.000",
+		"server.log.20250415-151345.000-2.gz",
+		"server.log.20250415-151345.000-10",
+		"server.log.20250415-151344.999-1",
+		"server.log.20250415-151345.000-x",
+		"server.log.20250415-151345.000-0",
+		"server.log.20250415",
+		"other.log.20250415-151346.000",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := sink.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, backup := range backups {
+		names = append(names, filepath.Base(backup.path))
+	}
+	expected := "server.log.20250415-151345.000-10, server.log.20250415-151345.000-2.gz, server.log.20250415-151345.000, server.log.20250415-151344.999-1"
+	if strings.Join(names, ", ") != expected {
+		t.Errorf("got %s", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SamplingOptions tells how a SamplingSink deduplicates and rate-limits the entries.
+type SamplingOptions struct {
+	// Window is the period over which identical entries are deduplicated and the rate limits are counted
+	Window time.Duration
+	// Deduplicate writes the first entry of a level, category and template in a window,
+	// the repetitions are summarized by a "repeated N times" entry at the end of the window
+	Deduplicate bool
+	// LevelLimits is the maximum number of entries of a level written in a window, missing for no limit
+	LevelLimits map[LogLevel]int
+	// CategoryLimits is the maximum number of entries of a category and its sub categories written in a window, missing for no limit
+	CategoryLimits map[string]int
+}
+
+// SamplingSink deduplicates and rate-limits the entries before writing them to the wrapped sink,
+// the suppressed entries are reported at the end of every window.
+type SamplingSink struct {
+	sink    ISink
+	options SamplingOptions
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	// repetitions are the deduplicated entries of the window, keys keeps them in the order they were first written
+	repetitions    map[samplingKey]*repetition
+	keys           []samplingKey
+	levelCounts    map[LogLevel]int
+	categoryCounts map[string]int
+	// limited counts the entries dropped by a limit, keyed by its description such as "level Debug"
+	limited map[string]int
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type samplingKey struct {
+	level    LogLevel
+	category string
+	template string
+}
+
+type repetition struct {
+	entry *Entry
+	count int
+}
+
+// NewSamplingSink wraps sink, the window is rolled over on the next entry or every Window by a background goroutine.
+func NewSamplingSink(sink ISink, options SamplingOptions) *SamplingSink {
+	s := &SamplingSink{
+		sink:    sink,
+		options: options,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	s.reset(time.Now())
+	go s.run()
+	return s
+}
+
+func (s *SamplingSink) Level() LogLevel {
+	return s.sink.Level()
+}
+
+func (s *SamplingSink) Write(entry *Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(s.windowStart) >= s.options.Window {
+		s.rollOver(now)
+	}
+	key := samplingKey{level: entry.Level, category: entry.Category, template: entry.Template}
+	if s.options.Deduplicate {
+		if repeated, ok := s.repetitions[key]; ok {
+			repeated.count++
+			repeated.entry = entry
+			return nil
+		}
+	}
+	if limit, ok := s.options.LevelLimits[entry.Level]; ok {
+		if s.levelCounts[entry.Level] >= limit {
+			s.limited["level "+entry.Level.String()]++
+			return nil
+		}
+	}
+	category, limit, limitedCategory := s.categoryLimit(entry.Category)
+	if limitedCategory && s.categoryCounts[category] >= limit {
+		s.limited["category "+category]++
+		return nil
+	}
+	s.levelCounts[entry.Level]++
+	if limitedCategory {
+		s.categoryCounts[category]++
+	}
+	if s.options.Deduplicate {
+		s.repetitions[key] = &repetition{entry: entry}
+		s.keys = append(s.keys, key)
+	}
+	return s.sink.Write(entry)
+}
+
+// Flush flushes the wrapped sink, the repetitions of the current window are still reported at its end.
+func (s *SamplingSink) Flush() error {
+	if flusher, ok := s.sink.(IFlusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Close reports the suppressed entries of the current window, then closes the wrapped sink.
+// Like AsyncSink.Close, the calls after the first one do nothing.
+func (s *SamplingSink) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		s.mutex.Lock()
+		s.rollOver(time.Now())
+		s.mutex.Unlock()
+		err = s.sink.Close()
+	})
+	return err
+}
+
+func (s *SamplingSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.options.Window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mutex.Lock()
+			if now.Sub(s.windowStart) >= s.options.Window {
+				s.rollOver(now)
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// categoryLimit returns the closest configured category of category, walking up the dot separated parents like the levels.
+func (s *SamplingSink) categoryLimit(category string) (string, int, bool) {
+	for {
+		if limit, ok := s.options.CategoryLimits[category]; ok {
+			return category, limit, true
+		}
+		index := strings.LastIndexByte(category, '.')
+		if index < 0 {
+			break
+		}
+		category = category[:index]
+	}
+	if limit, ok := s.options.CategoryLimits[""]; ok {
+		return "", limit, true
+	}
+	return "", 0, false
+}
+
+// rollOver writes the summaries of the window ending at now and starts a new one, the mutex must be held.
+func (s *SamplingSink) rollOver(now time.Time) {
+	for _, key := range s.keys {
+		repeated := s.repetitions[key]
+		if repeated.count == 0 {
+			continue
+		}
+		summary := *repeated.entry
+		summary.Time = now
+		summary.Message = fmt.Sprintf("%s (repeated %d times)", summary.Message, repeated.count)
+		s.write(&summary)
+	}
+	for _, limit := range slices.Sorted(maps.Keys(s.limited)) {
+		template := "Rate limited %d log entries of %s"
+		s.write(&Entry{
+			Time:     now,
+			Level:    Warning,
+			Category: "logging",
+			Template: template,
+			Message:  fmt.Sprintf(template, s.limited[limit], limit),
+			Args:     []interface{}{s.limited[limit], limit},
+		})
+	}
+	s.reset(now)
+}
+
+func (s *SamplingSink) reset(now time.Time) {
+	s.windowStart = now
+	s.repetitions = make(map[samplingKey]*repetition)
+	s.keys = nil
+	s.levelCounts = make(map[LogLevel]int)
+	s.categoryCounts = make(map[string]int)
+	s.limited = make(map[string]int)
+}
+
+func (s *SamplingSink) write(entry *Entry) {
+	if entry.Level < s.sink.Level() {
+		return
+	}
+	err := s.sink.Write(entry)
+	if err != nil {
+		reportSinkError(err)
+	}
+}
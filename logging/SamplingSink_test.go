@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// closeCountingSink is a recordingSink counting its Close calls.
+type closeCountingSink struct {
+	recordingSink
+	closes int
+}
+
+func (s *closeCountingSink) Close() error {
+	s.closes++
+	return nil
+}
+
+// newTestSamplingSink returns a SamplingSink whose window only ends when the test calls endWindow.
+func newTestSamplingSink(options SamplingOptions) (*SamplingSink, *closeCountingSink) {
+	recorder := &closeCountingSink{}
+	options.Window = time.Hour
+	return NewSamplingSink(recorder, options), recorder
+}
+
+// endWindow moves the start of the window of s back, so the next entry rolls it over.
+func endWindow(s *SamplingSink) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.windowStart = s.windowStart.Add(-s.options.Window)
+}
+
+func writeSampled(t *testing.T, sink ISink, level LogLevel, category string, template string, args ...interface{}) {
+	t.Helper()
+	err := sink.Write(&Entry{Time: time.Now(), Level: level, Category: category, Template: template, Message: fmt.Sprintf(template, args...), Args: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// messages returns the messages written to s.
+func (s *recordingSink) messages() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	messages := make([]string, len(s.entries))
+	for i, entry := range s.entries {
+		messages[i] = entry.Message
+	}
+	return messages
+}
+
+func expectMessages(t *testing.T, sink *recordingSink, expected ...string) {
+	t.Helper()
+	messages := sink.messages()
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Errorf("got %q, want %q", messages, expected)
+	}
+}
+
+func TestSamplingSinkCollapsesTheRepeats(t *testing.T) {
+	sampling, recorder := newTestSamplingSink(SamplingOptions{Deduplicate: true})
+	for i := range 4 {
+		writeSampled(t, sampling, Warning, "api", "retry %d", i)
+	}
+	writeSampled(t, sampling, Warning, "db", "retry %d", 0)
+	writeSampled(t, sampling, Error, "api", "retry %d", 0)
+	writeSampled(t, sampling, Warning, "api", "other")
+	expectMessages(t, &recorder.recordingSink, "retry 0", "retry 0", "retry 0", "other")
+
+	endWindow(sampling)
+	writeSampled(t, sampling, Warning, "api", "retry %d", 4)
+	// the summary has the last repetition, the next window writes the entry again
+	expectMessages(t, &recorder.recordingSink, "retry 0", "retry 0", "retry 0", "other", "retry 3 (repeated 3 times)", "retry 4")
+	if summary := recorder.entries[4]; summary.Level != Warning || summary.Category != "api" || summary.Template != "retry %d" {
+		t.Errorf("got the summary %+v", summary)
+	}
+	_ = sampling.Close()
+}
+
+func TestSamplingSinkRollsOverOnItsOwn(t *testing.T) {
+	recorder := &closeCountingSink{}
+	sampling := NewSamplingSink(recorder, SamplingOptions{Window: 10 * time.Millisecond, Deduplicate: true})
+	defer sampling.Close()
+	writeSampled(t, sampling, Warning, "api", "retry")
+	writeSampled(t, sampling, Warning, "api", "retry")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorder.messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	messages := recorder.messages()
+	// a slow machine may have started a window between the two writes, the second entry is then written as is
+	if len(messages) != 2 || messages[1] != "retry (repeated 1 times)" && messages[1] != "retry" {
+		t.Errorf("got %q", messages)
+	}
+}
+
+func TestSamplingSinkLimits(t *testing.T) {
+	sampling, recorder := newTestSamplingSink(SamplingOptions{
+		LevelLimits:    map[LogLevel]int{Debug: 2},
+		CategoryLimits: map[string]int{"api": 2, "api.Noisy": 1},
+	})
+	for i := range 3 {
+		writeSampled(t, sampling, Debug, "db", "query %d", i)
+	}
+	writeSampled(t, sampling, Information, "api.Perlin", "perlin")
+	writeSampled(t, sampling, Information, "api", "api")
+	writeSampled(t, sampling, Information, "api.Other", "other")
+	writeSampled(t, sampling, Information, "api.Noisy.Child", "noisy 0")
+	writeSampled(t, sampling, Information, "api.Noisy", "noisy 1")
+	for i := range 3 {
+		writeSampled(t, sampling, Information, "db", "unlimited %d", i)
+	}
+	expectMessages(t, &recorder.recordingSink, "query 0", "query 1", "perlin", "api", "noisy 0", "unlimited 0", "unlimited 1", "unlimited 2")
+
+	endWindow(sampling)
+	writeSampled(t, sampling, Debug, "db", "query %d", 3)
+	expectMessages(t, &recorder.recordingSink, "query 0", "query 1", "perlin", "api", "noisy 0", "unlimited 0", "unlimited 1", "unlimited 2",
+		"Rate limited 1 log entries of category api",
+		"Rate limited 1 log entries of category api.Noisy",
+		"Rate limited 1 log entries of level Debug",
+		"query 3")
+	_ = sampling.Close()
+}
+
+func TestSamplingSinkReportsTheWindowOnClose(t *testing.T) {
+	sampling, recorder := newTestSamplingSink(SamplingOptions{Deduplicate: true, LevelLimits: map[LogLevel]int{Debug: 0}})
+	writeSampled(t, sampling, Information, "api", "served")
+	writeSampled(t, sampling, Information, "api", "served")
+	writeSampled(t, sampling, Information, "api", "served")
+	writeSampled(t, sampling, Debug, "api", "hidden")
+	if err := sampling.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expectMessages(t, &recorder.recordingSink, "served", "served (repeated 2 times)", "Rate limited 1 log entries of level Debug")
+
+	if err := sampling.Close(); err != nil {
+		t.Errorf("the second Close fails: %v", err)
+	}
+	if recorder.closes != 1 {
+		t.Errorf("the wrapped sink is closed %d times", recorder.closes)
+	}
+	if len(recorder.messages()) != 3 {
+		t.Errorf("the second Close writes %q", recorder.messages()[3:])
+	}
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ISink writes the entries at or above its own level, it must be safe for concurrent use.
+type ISink interface {
+	// Level is the minimum level written to the sink, the logger level filters the entries first
+	Level() LogLevel
+	Write(entry *Entry) error
+	io.Closer
+}
+
+// WriterSink writes the formatted entries to an io.Writer such as os.Stdout or os.Stderr.
+type WriterSink struct {
+	level     LogLevel
+	formatter IFormatter
+
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterSink(writer io.Writer, level LogLevel, formatter IFormatter) *WriterSink {
+	return &WriterSink{
+		level:     level,
+		formatter: formatter,
+		writer:    writer,
+	}
+}
+
+func (s *WriterSink) Level() LogLevel {
+	return s.level
+}
+
+func (s *WriterSink) Write(entry *Entry) error {
+	line := s.formatter.Format(entry)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.writer.Write(line)
+	return err
+}
+
+// Close does nothing, the writer is owned by the caller.
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// output redacts the entries and fans them out to the sinks, which can be replaced while logging.
+type output struct {
+	mutex    sync.RWMutex
+	sinks    []ISink
+	redactor *Redactor
+}
+
+func newOutput(sinks ...ISink) *output {
+	return &output{sinks: sinks, redactor: DefaultRedactor()}
+}
+
+func (o *output) write(entry *Entry) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	if o.redactor != nil {
+		entry = o.redactor.Redact(entry)
+	}
+	for _, sink := range o.sinks {
+		if entry.Level < sink.Level() {
+			continue
+		}
+		err := sink.Write(entry)
+		if err != nil {
+			reportSinkError(err)
+		}
+	}
+}
+
+// reportSinkError writes err to stderr, the sinks are the only way to report errors so it is the last resort.
+func reportSinkError(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "Error writing log entry: %v\n", err)
+}
+
+func (o *output) flush() error {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	var errs []error
+	for _, sink := range o.sinks {
+		if flusher, ok := sink.(IFlusher); ok {
+			if err := flusher.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setSinks replaces the sinks and returns the previous ones, which are not used anymore once it returned.
+func (o *output) setSinks(sinks []ISink) []ISink {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	previous := o.sinks
+	o.sinks = sinks
+	return previous
+}
+
+func (o *output) setRedactor(redactor *Redactor) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.redactor = redactor
+}
+
+func closeSinks(sinks []ISink) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,245 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// The LogLevel and slog.Level scales map as follows, slog levels between two steps round down:
+//
+//	Trace       <-> slog.LevelDebug - 8 (-12)
+//	Verbose     <-> slog.LevelDebug - 4 (-8)
+//	Debug       <-> slog.LevelDebug     (-4)
+//	Information <-> slog.LevelInfo      (0)
+//	Warning     <-> slog.LevelWarn      (4)
+//	Error       <-> slog.LevelError     (8)
+//	Fatal       <-> slog.LevelError + 4 (12)
+//
+// Lowest and Highest map to the lowest and highest slog.Level, None is not a level and maps to slog.LevelInfo.
+const (
+	slogLevelTrace   = slog.LevelDebug - 8
+	slogLevelVerbose = slog.LevelDebug - 4
+	slogLevelFatal   = slog.LevelError + 4
+)
+
+// ToSlogLevel converts level to the slog.Level scale.
+func ToSlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case Lowest:
+		return slog.Level(math.MinInt)
+	case Trace:
+		return slogLevelTrace
+	case Verbose:
+		return slogLevelVerbose
+	case Debug:
+		return slog.LevelDebug
+	case Warning:
+		return slog.LevelWarn
+	case Error:
+		return slog.LevelError
+	case Fatal:
+		return slogLevelFatal
+	case Highest:
+		return slog.Level(math.MaxInt)
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// FromSlogLevel converts level to the LogLevel scale.
+func FromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slogLevelVerbose:
+		return Trace
+	case level < slog.LevelDebug:
+		return Verbose
+	case level < slog.LevelInfo:
+		return Debug
+	case level < slog.LevelWarn:
+		return Information
+	case level < slog.LevelError:
+		return Warning
+	case level < slogLevelFatal:
+		return Error
+	default:
+		return Fatal
+	}
+}
+
+// slogHandler is a slog.Handler writing the records to an ILogger, the attributes become fields.
+type slogHandler struct {
+	logger ILogger
+	// group prefixes the keys of the attributes, e.g. "request." after WithGroup("request")
+	group string
+}
+
+// NewSlogHandler returns a slog.Handler backed by logger, e.g. for http.Server.ErrorLog through slog.NewLogLogger
+// or for slog.SetDefault so the packages using slog or log write to the same sinks.
+func NewSlogHandler(logger ILogger) slog.Handler {
+	return &slogHandler{logger: logger}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return FromSlogLevel(level) >= h.logger.LogLevel()
+}
+
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	var fields []Field
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.group, attr)
+		return true
+	})
+	level := FromSlogLevel(record.Level)
+	if l, ok := h.logger.(*logger); ok {
+		// the record knows its caller, so the skip of ILogger.Log isn't needed
+		entry := &Entry{
+			Time:     record.Time,
+			Level:    level,
+			Template: record.Message,
+			Message:  record.Message,
+			Fields:   fields,
+		}
+		l.CallerFormat().setCaller(entry, record.PC)
+		l.write(entry)
+		return nil
+	}
+	target := h.logger
+	for _, field := range fields {
+		target = target.With(field.Key, field.Value)
+	}
+	// skip Handle and the slog.Logger methods
+	target.Log(level, "%s", 4, record.Message)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []Field
+	for _, attr := range attrs {
+		fields = appendSlogAttr(fields, h.group, attr)
+	}
+	target := h.logger
+	for _, field := range fields {
+		target = target.With(field.Key, field.Value)
+	}
+	return &slogHandler{logger: target, group: h.group}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, group: h.group + name + "."}
+}
+
+// appendSlogAttr appends attr as fields, the attributes of groups are flattened into "group.key" fields.
+func appendSlogAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, groupAttr := range value.Group() {
+			fields = appendSlogAttr(fields, prefix, groupAttr)
+		}
+		return fields
+	}
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	return append(fields, Field{Key: prefix + attr.Key, Value: value.Any()})
+}
+
+// slogLogger is an ILogger writing to a slog.Handler, the fields become attributes.
+type slogLogger struct {
+	handler slog.Handler
+	// level is shared by the copies of the logger made by With, the handler filters the records too
+	level *atomic.Int64
+	// callerOff stops reporting the caller to the handler, it is shared like level. The handler renders the caller on its own
+	callerOff *atomic.Bool
+}
+
+// NewSlogLogger returns an ILogger backed by handler, its own level is Lowest so the handler decides what is written.
+func NewSlogLogger(handler slog.Handler) ILogger {
+	l := &slogLogger{
+		handler:   handler,
+		level:     &atomic.Int64{},
+		callerOff: &atomic.Bool{},
+	}
+	l.level.Store(int64(Lowest))
+	return l
+}
+
+func (l *slogLogger) Log(level LogLevel, msg string, skip int, args ...interface{}) {
+	ctx := context.Background()
+	if level < l.LogLevel() || !l.handler.Enabled(ctx, ToSlogLevel(level)) {
+		return
+	}
+	var pcs [1]uintptr
+	if !l.callerOff.Load() {
+		// runtime.Callers counts itself, unlike runtime.Caller
+		runtime.Callers(skip+1, pcs[:])
+	}
+	record := slog.NewRecord(time.Now(), ToSlogLevel(level), fmt.Sprintf(msg, args...), pcs[0])
+	_ = l.handler.Handle(ctx, record)
+}
+
+func (l *slogLogger) Trace(msg string, args ...interface{}) {
+	l.Log(Trace, msg, 2, args...)
+}
+
+func (l *slogLogger) Verbose(msg string, args ...interface{}) {
+	l.Log(Verbose, msg, 2, args...)
+}
+
+func (l *slogLogger) Debug(msg string, args ...interface{}) {
+	l.Log(Debug, msg, 2, args...)
+}
+
+func (l *slogLogger) Information(msg string, args ...interface{}) {
+	l.Log(Information, msg, 2, args...)
+}
+
+func (l *slogLogger) Warning(msg string, args ...interface{}) {
+	l.Log(Warning, msg, 2, args...)
+}
+
+func (l *slogLogger) Error(msg string, args ...interface{}) {
+	l.Log(Error, msg, 2, args...)
+}
+
+func (l *slogLogger) Fatal(msg string, args ...interface{}) {
+	l.Log(Fatal, msg, 2, args...)
+}
+
+func (l *slogLogger) LogLevel() LogLevel {
+	return LogLevel(l.level.Load())
+}
+
+func (l *slogLogger) SetLogLevel(level LogLevel) {
+	l.level.Store(int64(level))
+}
+
+func (l *slogLogger) CallerFormat() CallerFormat {
+	if l.callerOff.Load() {
+		return CallerOff
+	}
+	return CallerFull
+}
+
+// SetCallerFormat only tells whether the caller is given to the handler, which renders it on its own.
+func (l *slogLogger) SetCallerFormat(format CallerFormat) {
+	l.callerOff.Store(format == CallerOff)
+}
+
+func (l *slogLogger) With(key string, value interface{}) ILogger {
+	return &slogLogger{
+		handler:   l.handler.WithAttrs([]slog.Attr{slog.Any(key, value)}),
+		level:     l.level,
+		callerOff: l.callerOff,
+	}
+}
@@ -0,0 +1,70 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"errors"
+	"log/syslog"
+	"strings"
+)
+
+// SyslogSink writes the formatted entries to the local syslog daemon, the levels are mapped to the syslog severities.
+type SyslogSink struct {
+	level     LogLevel
+	formatter IFormatter
+	writer    *syslog.Writer
+}
+
+// NewSyslogSink connects to the syslog Unix socket at address, or to the usual local sockets (/dev/log...) when it is empty.
+// tag is prepended to every message, the program name when it is empty.
+func NewSyslogSink(address string, tag string, level LogLevel, formatter IFormatter) (*SyslogSink, error) {
+	var writer *syslog.Writer
+	var err error
+	if address == "" {
+		writer, err = syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	} else {
+		// syslog daemons listen on datagram sockets, some on stream ones
+		writer, err = syslog.Dial("unixgram", address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+		if err != nil {
+			var streamErr error
+			writer, streamErr = syslog.Dial("unix", address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+			if streamErr != nil {
+				err = errors.Join(err, streamErr)
+			} else {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{
+		level:     level,
+		formatter: formatter,
+		writer:    writer,
+	}, nil
+}
+
+func (s *SyslogSink) Level() LogLevel {
+	return s.level
+}
+
+func (s *SyslogSink) Write(entry *Entry) error {
+	message := strings.TrimSuffix(string(s.formatter.Format(entry)), "\n")
+	switch {
+	case entry.Level <= Debug:
+		return s.writer.Debug(message)
+	case entry.Level == Information:
+		return s.writer.Info(message)
+	case entry.Level == Warning:
+		return s.writer.Warning(message)
+	case entry.Level == Error:
+		return s.writer.Err(message)
+	default:
+		return s.writer.Crit(message)
+	}
+}
+
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
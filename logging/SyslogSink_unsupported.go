@@ -0,0 +1,27 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+	"runtime"
+)
+
+// SyslogSink is not available on this platform, NewSyslogSink always fails.
+type SyslogSink struct{}
+
+func NewSyslogSink(address string, tag string, level LogLevel, formatter IFormatter) (*SyslogSink, error) {
+	return nil, errors.New("syslog is not supported on " + runtime.GOOS)
+}
+
+func (s *SyslogSink) Level() LogLevel {
+	return Highest
+}
+
+func (s *SyslogSink) Write(entry *Entry) error {
+	return errors.New("syslog is not supported on " + runtime.GOOS)
+}
+
+func (s *SyslogSink) Close() error {
+	return nil
+}
@@ -2,14 +2,25 @@ package logging
 
 import (
 	"fmt"
+	"os"
 	"runtime"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
 type logger struct {
-	level            LogLevel
-	colorProvider    func(LogLevel) string
-	useSourceContext bool
+	// category is printed before the message, empty for the root logger
+	category string
+	// fields are printed after the message, see With
+	fields []Field
+	// levels is shared by the loggers of the same factory so SetLogLevel applies to all of them
+	levels *categoryLevels
+	// output is shared by the loggers of the same factory so the sinks can be replaced while logging
+	output *output
+	// callerFormat is set by SetCallerFormat, nil to use defaultCallerFormat which is shared by the loggers of the same factory
+	callerFormat        atomic.Pointer[CallerFormat]
+	defaultCallerFormat *atomic.Pointer[CallerFormat]
 }
 
 const (
@@ -23,68 +34,122 @@ const (
 	colorFatal       = "\033[0;35m"
 )
 
-func (l logger) Log(level LogLevel, msg string, skip int, args ...interface{}) {
-	if level < l.level {
+func (l *logger) Log(level LogLevel, msg string, skip int, args ...interface{}) {
+	if level < l.LogLevel() {
 		return
 	}
-	var sourceContext string
-	if l.useSourceContext {
-		_, file, line, ok := runtime.Caller(skip)
-		if ok == false {
+	entry := &Entry{
+		Time:     time.Now(),
+		Level:    level,
+		Template: msg,
+		Message:  fmt.Sprintf(msg, args...),
+		Args:     args,
+	}
+	format := l.CallerFormat()
+	if format != CallerOff {
+		var pcs [1]uintptr
+		// runtime.Callers counts itself, unlike runtime.Caller
+		if runtime.Callers(skip+1, pcs[:]) == 0 {
 			// if we can't get the caller, we disable the source context
+			l.SetCallerFormat(CallerOff)
 			l.Error("Can't get caller, disabling source context")
-			l.useSourceContext = false
 		} else {
-			sourceContext = fmt.Sprintf(" [%s:%d]", file, line)
+			format.setCaller(entry, pcs[0])
 		}
 	}
-	if l.colorProvider != nil {
-		// [2025-04-15 15:13:45 D] [main.go:15]: message
-		fmt.Printf("%s[%s %c]%s: %s%s\n", l.colorProvider(level), time.Now().Format("2006-01-02 15:04:05.000"), level.String()[0], sourceContext, fmt.Sprintf(msg, args...), l.colorProvider(None))
+	l.write(entry)
+}
+
+// write completes entry with the category and the fields of the logger, then writes it to the sinks.
+// The level is checked by the caller.
+func (l *logger) write(entry *Entry) {
+	entry.Category = l.category
+	if len(entry.Fields) == 0 {
+		entry.Fields = l.fields
 	} else {
-		fmt.Printf("[%s %c]%s: %s\n", time.Now().Format("2006-01-02 15:04:05.000"), level.String()[0], sourceContext, fmt.Sprintf(msg, args...))
+		entry.Fields = append(slices.Clip(l.fields), entry.Fields...)
 	}
+	l.output.write(entry)
 }
 
+// NewLogger creates a root logger with its own level writing console lines to stdout, use a LoggerFactory for category loggers.
 func NewLogger(level LogLevel) *logger {
+	return newLogger("", newCategoryLevels(level), newOutput(NewWriterSink(os.Stdout, Lowest, NewFormatter(ConsoleFormat, DetectColors(os.Stdout, DefaultColorTheme())))), newDefaultCallerFormat())
+}
+
+func newLogger(category string, levels *categoryLevels, output *output, defaultCallerFormat *atomic.Pointer[CallerFormat]) *logger {
 	return &logger{
-		level:            level,
-		colorProvider:    DefaultColor,
-		useSourceContext: true,
+		category:            category,
+		levels:              levels,
+		output:              output,
+		defaultCallerFormat: defaultCallerFormat,
 	}
 }
 
-func (l logger) Trace(msg string, args ...interface{}) {
+func newDefaultCallerFormat() *atomic.Pointer[CallerFormat] {
+	format := CallerShort
+	pointer := &atomic.Pointer[CallerFormat]{}
+	pointer.Store(&format)
+	return pointer
+}
+
+func (l *logger) With(key string, value interface{}) ILogger {
+	derived := newLogger(l.category, l.levels, l.output, l.defaultCallerFormat)
+	// copy so the fields of l are never shared with the new logger
+	derived.fields = append(slices.Clip(l.fields), Field{Key: key, Value: value})
+	derived.callerFormat.Store(l.callerFormat.Load())
+	return derived
+}
+
+func (l *logger) Trace(msg string, args ...interface{}) {
 	l.Log(Trace, msg, 2, args...)
 }
 
-func (l logger) Verbose(msg string, args ...interface{}) {
+func (l *logger) Verbose(msg string, args ...interface{}) {
 	l.Log(Verbose, msg, 2, args...)
 }
 
-func (l logger) Debug(msg string, args ...interface{}) {
+func (l *logger) Debug(msg string, args ...interface{}) {
 	l.Log(Debug, msg, 2, args...)
 }
 
-func (l logger) Information(msg string, args ...interface{}) {
+func (l *logger) Information(msg string, args ...interface{}) {
 	l.Log(Information, msg, 2, args...)
 }
 
-func (l logger) Warning(msg string, args ...interface{}) {
+func (l *logger) Warning(msg string, args ...interface{}) {
 	l.Log(Warning, msg, 2, args...)
 }
 
-func (l logger) Error(msg string, args ...interface{}) {
+func (l *logger) Error(msg string, args ...interface{}) {
 	l.Log(Error, msg, 2, args...)
 }
 
-func (l logger) Fatal(msg string, args ...interface{}) {
+func (l *logger) Fatal(msg string, args ...interface{}) {
 	l.Log(Fatal, msg, 2, args...)
 }
 
-func (l logger) LogLevel() LogLevel {
-	return l.level
+func (l *logger) LogLevel() LogLevel {
+	return l.levels.levelOf(l.category)
 }
+
+// SetLogLevel sets the level of the logger category, for every logger of the same category.
+func (l *logger) SetLogLevel(level LogLevel) {
+	l.levels.set(l.category, level)
+}
+
+func (l *logger) CallerFormat() CallerFormat {
+	if format := l.callerFormat.Load(); format != nil {
+		return *format
+	}
+	return *l.defaultCallerFormat.Load()
+}
+
+// SetCallerFormat sets the caller format of this logger only, the loggers created by With afterwards inherit it.
+func (l *logger) SetCallerFormat(format CallerFormat) {
+	l.callerFormat.Store(&format)
+}
+
 func DefaultColor(l LogLevel) string {
 	switch l {
 	case None:
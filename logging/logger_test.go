@@ -0,0 +1,234 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingSink keeps the entries written to it.
+type recordingSink struct {
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+func (s *recordingSink) Level() LogLevel {
+	return Lowest
+}
+
+func (s *recordingSink) Write(entry *Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries = append(s.entries, *entry)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+func (s *recordingSink) last(t *testing.T) Entry {
+	t.Helper()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.entries) == 0 {
+		t.Fatal("nothing was logged")
+	}
+	return s.entries[len(s.entries)-1]
+}
+
+func newRecordingFactory(format CallerFormat) (*LoggerFactory, *recordingSink) {
+	sink := &recordingSink{}
+	factory := NewLoggerFactory(Lowest, nil)
+	_ = factory.SetSinks(sink)
+	factory.SetCallerFormat(format)
+	return factory, sink
+}
+
+// nextLine returns the line following the call, where the test logs.
+func nextLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line + 1
+}
+
+func TestLevelMethodsReportTheirCaller(t *testing.T) {
+	methods := map[LogLevel]func(logger ILogger) int{
+		Trace: func(logger ILogger) int {
+			line := nextLine()
+			logger.Trace("message")
+			return line
+		},
+		Verbose: func(logger ILogger) int {
+			line := nextLine()
+			logger.Verbose("message")
+			return line
+		},
+		Debug: func(logger ILogger) int {
+			line := nextLine()
+			logger.Debug("message")
+			return line
+		},
+		Information: func(logger ILogger) int {
+			line := nextLine()
+			logger.Information("message")
+			return line
+		},
+		Warning: func(logger ILogger) int {
+			line := nextLine()
+			logger.Warning("message")
+			return line
+		},
+		Error: func(logger ILogger) int {
+			line := nextLine()
+			logger.Error("message")
+			return line
+		},
+		Fatal: func(logger ILogger) int {
+			line := nextLine()
+			logger.Fatal("message")
+			return line
+		},
+	}
+	factory, sink := newRecordingFactory(CallerShort)
+	logger := factory.CreateLogger("test")
+	for level, method := range methods {
+		line := method(logger)
+		entry := sink.last(t)
+		if entry.Level != level || entry.File != "logger_test.go" || entry.Line != line {
+			t.Errorf("%s is reported at %s:%d, want logger_test.go:%d", level, entry.File, entry.Line, line)
+		}
+	}
+}
+
+func TestWithLoggersReportTheirCaller(t *testing.T) {
+	factory, sink := newRecordingFactory(CallerShort)
+	logger := factory.CreateLogger("test").With("a", 1).With("b", 2)
+
+	line := nextLine()
+	logger.Information("message")
+	entry := sink.last(t)
+	if entry.Source() != "logger_test.go:"+strconv.Itoa(line) {
+		t.Errorf("reported at %s, want line %d", entry.Source(), line)
+	}
+	if len(entry.Fields) != 2 {
+		t.Errorf("got fields %v", entry.Fields)
+	}
+
+	line = nextLine()
+	logger.Log(Warning, "message", 1)
+	if entry = sink.last(t); entry.Line != line {
+		t.Errorf("Log with skip 1 is reported at line %d, want %d", entry.Line, line)
+	}
+}
+
+func TestCallerFormats(t *testing.T) {
+	pc, file, _, _ := runtime.Caller(0)
+	function := runtime.FuncForPC(pc).Name()
+	function = function[strings.LastIndexByte(function, '/')+1:]
+	tests := []struct {
+		format   CallerFormat
+		file     string
+		function string
+	}{
+		{CallerOff, "", ""},
+		{CallerShort, filepath.Base(file), ""},
+		{CallerFunction, "", function},
+		{CallerFull, file, ""},
+	}
+	for _, test := range tests {
+		factory, sink := newRecordingFactory(test.format)
+		line := nextLine()
+		factory.CreateLogger("test").Information("message")
+		entry := sink.last(t)
+		if test.format == CallerOff {
+			line = 0
+		}
+		if entry.File != test.file || entry.Function != test.function || entry.Line != line {
+			t.Errorf("%s reports %q %q %d, want %q %q %d", test.format, entry.File, entry.Function, entry.Line, test.file, test.function, line)
+		}
+	}
+}
+
+func TestLoggerCallerFormatOverridesTheFactory(t *testing.T) {
+	factory, sink := newRecordingFactory(CallerShort)
+	logger := factory.CreateLogger("test")
+	logger.SetCallerFormat(CallerOff)
+	derived := logger.With("a", 1)
+	derived.Information("message")
+	if entry := sink.last(t); entry.Source() != "" {
+		t.Errorf("a logger with CallerOff reports %s", entry.Source())
+	}
+	factory.CreateLogger("test").Information("message")
+	if entry := sink.last(t); entry.Source() == "" {
+		t.Error("the other loggers of the factory don't report the caller anymore")
+	}
+}
+
+func TestMissingCallerDisablesTheSource(t *testing.T) {
+	factory, sink := newRecordingFactory(CallerShort)
+	logger := factory.CreateLogger("test")
+	logger.Log(Information, "message", 1000)
+	if len(sink.entries) != 2 {
+		t.Fatalf("got %d entries", len(sink.entries))
+	}
+	if sink.entries[0].Level != Error || sink.entries[1].Source() != "" {
+		t.Errorf("got %+v", sink.entries)
+	}
+	if logger.CallerFormat() != CallerOff {
+		t.Errorf("the caller format is %s", logger.CallerFormat())
+	}
+}
+
+func TestSlogHandlerReportsTheCallerOfSlog(t *testing.T) {
+	factory, sink := newRecordingFactory(CallerShort)
+	logger := slog.New(NewSlogHandler(factory.CreateLogger("test"))).With("a", 1)
+
+	line := nextLine()
+	logger.Info("message", "b", 2)
+	entry := sink.last(t)
+	if entry.Source() != "logger_test.go:"+strconv.Itoa(line) {
+		t.Errorf("reported at %s, want line %d", entry.Source(), line)
+	}
+}
+
+// slogSource returns the source of the last record written by a slog.JSONHandler with AddSource.
+func slogSource(t *testing.T, output *bytes.Buffer) (string, int) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	var record struct {
+		Source struct {
+			File string `json:"file"`
+			Line int    `json:"line"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Base(record.Source.File), record.Source.Line
+}
+
+func TestSlogLoggerReportsItsCaller(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := NewSlogLogger(slog.NewJSONHandler(output, &slog.HandlerOptions{AddSource: true, Level: slog.Level(-100)})).With("a", 1)
+
+	line := nextLine()
+	logger.Debug("message")
+	if file, got := slogSource(t, output); file != "logger_test.go" || got != line {
+		t.Errorf("reported at %s:%d, want line %d", file, got, line)
+	}
+
+	// through a slog handler which is not backed by a *logger, Handle adds its own frames
+	output.Reset()
+	wrapped := slog.New(NewSlogHandler(logger))
+	line = nextLine()
+	wrapped.Warn("message")
+	if file, got := slogSource(t, output); file != "logger_test.go" || got != line {
+		t.Errorf("reported at %s:%d through slog, want line %d", file, got, line)
+	}
+}
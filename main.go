@@ -2,36 +2,98 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/swaggest/openapi-go/openapi3"
 	"httpServer/api"
 	"httpServer/logging"
 	"httpServer/services"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"net/http"
 	"os"
-	"strconv"
+	"slices"
+	"strings"
+	"time"
 )
 
 func main() {
+	os.Exit(executeCommandLine(os.Args[1:]))
+}
+
+// serve runs the server until it is stopped and returns the process exit code.
+func serve(configPath string, configurationFlags *services.ConfigurationFlags) int {
 	log := configureLogger(logging.Information)
-	config, err := configureConfiguration(log)
+	config, sources, err := configureConfiguration(log, configPath, configurationFlags)
 	if err != nil {
 		log.Warning("Error configuring application: %v", err)
-		return
+		return 1
+	}
+	loggerFactory := logging.NewLoggerFactory(config.LogLevel, config.LogLevels)
+	loggerFactory.SetCallerFormat(config.LogCaller)
+	redactor, err := config.LogRedaction.CreateRedactor()
+	if err != nil {
+		log.Warning("Error configuring log redaction: %v", err)
+		return 1
+	}
+	loggerFactory.SetRedactor(redactor)
+	sinks, err := config.CreateLogSinks()
+	if err != nil {
+		log.Warning("Error configuring log sinks: %v", err)
+		return 1
+	}
+	err = loggerFactory.SetSinks(sinks...)
+	defer loggerFactory.Close()
+	log = loggerFactory.CreateLogger("")
+	if err != nil {
+		log.Warning("Error closing the startup log sinks: %v", err)
 	}
-	log = configureLogger(config.LogLevel)
+	// the packages using slog or log write to the same sinks
+	slog.SetDefault(slog.New(logging.NewSlogHandler(loggerFactory.CreateLogger("slog"))))
 	log.Information("Logging on level %s", config.LogLevel.String())
+	for _, path := range slices.Sorted(maps.Keys(sources)) {
+		log.Debug("Configuration %s loaded from %s", path, sources[path])
+	}
+
+	accessLog := services.NewAccessLog()
+	err = accessLog.Configure(config)
+	if err != nil {
+		log.Warning("Error configuring access log: %v", err)
+		return 1
+	}
+	defer accessLog.Close()
 
 	sp := services.NewEmptyServiceProvider()
-	sp.AddLogger(log)
+	sp.AddLoggerFactory(loggerFactory)
 	sp.AddConfiguration(config)
-	sp.AddHttpService(func() *services.HttpService {
-		server := configureHttpServer(sp)
-		return services.NewHttpService(server, nil)
-	}())
-	sp.Run(context.Background())
+	server, err := configureHttpServer(sp, accessLog)
+	if err != nil {
+		log.Warning("Error configuring routes: %v", err)
+		return 1
+	}
+	httpService := services.NewHttpService(server, config.ListenAddresses(), nil)
+	if config.Tls.Enabled() {
+		err = httpService.UseTls(&config.Tls)
+		if err != nil {
+			log.Warning("Error configuring TLS: %v", err)
+			return 1
+		}
+	}
+	sp.AddService("configurationWatcher", services.NewConfigurationWatcher(configPath, time.Duration(config.ReloadInterval)*time.Second, func() (*services.Configuration, error) {
+		config, _, err := configureConfiguration(log, configPath, configurationFlags)
+		return config, err
+	}))
+	sp.AddService("http", httpService, "configurationWatcher")
+	configureConfigurationReload(sp, loggerFactory, accessLog)
+	err = sp.Run(context.Background())
+	if err != nil {
+		log.Error("Application failed: %v", err)
+		return 1
+	}
 
 	log.Information("Exiting")
+	return 0
 }
 
 func configureLogger(logLevel logging.LogLevel) logging.ILogger {
@@ -39,95 +101,133 @@ func configureLogger(logLevel logging.LogLevel) logging.ILogger {
 	return log
 }
 
-func configureConfiguration(logger logging.ILogger) (*services.Configuration, error) {
-	// Read from json
-	var config services.Configuration
-	configFile, err := os.ReadFile("config.json")
-	if err != nil && os.IsNotExist(err) {
-		// If the file does not exist, create it with default values
-		config = *services.NewDefaultConfig()
-		configFile, err = json.MarshalIndent(config, "", "  ")
-		if err != nil {
-			logger.Warning("Error creating config file: %v, exiting", err)
-			return nil, err
+// configureConfiguration loads the Configuration from every layer, errors are returned to the caller which decides to exit or not.
+func configureConfiguration(logger logging.ILogger, configPath string, configurationFlags *services.ConfigurationFlags) (*services.Configuration, services.ConfigurationSources, error) {
+	loader := services.NewConfigurationLoader()
+	// Read from json, the file is optional and never written here, use `config init` to create one
+	err := loader.ApplyJsonFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Information("Config file %s not found, using default values", configPath)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("reading config file: %w", err)
+	}
+	// Read from env
+	err = loader.ApplyEnvironment(os.LookupEnv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading environment variables: %w", err)
+	}
+	// Read from command line
+	err = loader.ApplyFlags(configurationFlags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading command line: %w", err)
+	}
+	err = loader.Configuration().Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return loader.Configuration(), loader.Sources(), nil
+}
+
+// restartRequiredConfigurations are the json paths (or path prefixes ending with ".") read only once at startup.
+var restartRequiredConfigurations = []string{"port", "host", "listen", "reloadInterval", "tls."}
+
+// logSinkConfigurations are the json paths (or path prefixes ending with ".") which need the log sinks to be created again.
+var logSinkConfigurations = []string{"logFormat", "logColors", "logSinks", "logQueue.", "logSampling."}
+
+// accessLogConfigurations are the json paths (or path prefixes ending with ".") which need the access log to be configured again.
+var accessLogConfigurations = []string{"accessLog.", "logQueue.", "logRedaction."}
+
+func configureConfigurationReload(sp *services.ServiceProvider, loggerFactory *logging.LoggerFactory, accessLog *services.AccessLog) {
+	sp.OnConfigurationChanged(func(previous *services.Configuration, current *services.Configuration) {
+		changed := services.ChangedConfigurationPaths(previous, current)
+		if previous.LogLevel != current.LogLevel || !maps.Equal(previous.LogLevels, current.LogLevels) {
+			loggerFactory.SetLevels(current.LogLevel, current.LogLevels)
+			sp.Logger.Information("Logging on level %s, category levels %v", current.LogLevel.String(), current.LogLevels)
 		}
-		err = os.WriteFile("config.json", configFile, 0644)
-		if err != nil {
-			logger.Warning("Error creating config file: %v, exiting", err)
-			return nil, err
+		if previous.LogCaller != current.LogCaller {
+			loggerFactory.SetCallerFormat(current.LogCaller)
 		}
-		logger.Information("Config file created with default values, exiting")
-		return nil, err
-	} else {
-		err = json.Unmarshal(configFile, &config)
-		if err != nil {
-			logger.Warning("Error reading config file: %v, creating new one", err)
-			err = os.Rename("config.json", "config.json.bak")
+		if slices.ContainsFunc(changed, func(path string) bool { return matchConfigurationPath(path, []string{"logRedaction."}) }) {
+			redactor, err := current.LogRedaction.CreateRedactor()
 			if err != nil {
-				logger.Warning("Error backing up config file: %v, exiting", err)
-				return nil, err
+				sp.Logger.Warning("Error configuring log redaction, keeping the current one: %v", err)
+			} else {
+				loggerFactory.SetRedactor(redactor)
 			}
-			config = *services.NewDefaultConfig()
-			configFile, _ = json.MarshalIndent(config, "", "  ")
-			err = os.WriteFile("config.json", configFile, 0644)
+		}
+		if slices.ContainsFunc(changed, func(path string) bool { return matchConfigurationPath(path, logSinkConfigurations) }) {
+			sinks, err := current.CreateLogSinks()
 			if err != nil {
-				logger.Warning("Error creating config file: %v, exiting", err)
-				return nil, err
+				sp.Logger.Warning("Error configuring log sinks, keeping the current ones: %v", err)
+			} else {
+				err = loggerFactory.SetSinks(sinks...)
+				if err != nil {
+					sp.Logger.Warning("Error closing the previous log sinks: %v", err)
+				}
+				sp.Logger.Information("Logging to %d sinks in format %s", len(sinks), current.LogFormat)
+			}
+		}
+		if slices.ContainsFunc(changed, func(path string) bool { return matchConfigurationPath(path, accessLogConfigurations) }) {
+			err := accessLog.Configure(current)
+			if err != nil {
+				sp.Logger.Warning("Error configuring access log, keeping the current one: %v", err)
+			}
+		}
+		for _, path := range changed {
+			if matchConfigurationPath(path, restartRequiredConfigurations) {
+				sp.Logger.Warning("Configuration %s changed, restart the application to apply it", path)
 			}
-			logger.Information("Config file created with default values, exiting")
-			return nil, err
 		}
-		return &config, nil
+	})
+}
+
+// matchConfigurationPath reports whether path is one of patterns, or is under one of the patterns ending with ".".
+func matchConfigurationPath(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if path == pattern || (strings.HasSuffix(pattern, ".") && strings.HasPrefix(path, pattern)) {
+			return true
+		}
 	}
-	// Read from env
+	return false
 }
 
-func configureHttpServer(sp *services.ServiceProvider) *http.Server {
-	var err error
-	routeBuilder := api.NewRouteBuilder(sp)
+// configureHttpServer registers the routes, it fails when a route and the OpenApi file don't match.
+func configureHttpServer(sp *services.ServiceProvider, accessLog *services.AccessLog) (*http.Server, error) {
 	openApiBuilder := api.NewOpenApiBuilder()
 	configureOpenApiBasics(openApiBuilder.OpenApiReflector)
-	routeBuilder.Mux.Handle("/", http.RedirectHandler("/api/openapi", http.StatusFound))
-	api.RouteScalarClient("/api/openapi", routeBuilder)
-	api.RouteOpenApiFile("/api/openapi/openapi.json", routeBuilder, openApiBuilder)
-	err = api.ConfigureOpenApiFile("/api/openapi/openapi.json", openApiBuilder)
-	if err != nil {
-		sp.Logger.Warning("Error configuring OpenApi file: %v", err)
-		err = nil
-	}
-	api.RoutePerlinNoise("/api/perlin_noise", routeBuilder)
-	err = api.ConfigurePerlinNoise("/api/perlin_noise", openApiBuilder)
-	if err != nil {
-		sp.Logger.Warning("Error configuring Perlin noise: %v", err)
-		err = nil
-	}
-	api.RouteDrunkBishop("/api/drunk_bishop", routeBuilder)
-	err = api.ConfigureDrunkBishop("/api/drunk_bishop", openApiBuilder)
+	routeBuilder := api.NewRouteBuilder(sp, openApiBuilder)
+	// the request scope first, so the access log knows the request id and the authenticated user
+	routeBuilder.Use(sp.RequestScopeMiddleware, accessLog.Middleware)
+	routeBuilder.Handle("/", http.RedirectHandler("/api/openapi", http.StatusFound))
+	err := errors.Join(
+		api.RouteScalarClient("/api/openapi", routeBuilder),
+		api.RouteOpenApiFile("/api/openapi/openapi.json", routeBuilder),
+		api.RoutePerlinNoise("/api/perlin_noise", routeBuilder),
+		api.RouteDrunkBishop("/api/drunk_bishop", routeBuilder),
+		api.RouteBrainFxxkInterpretor("/api/brain_fxxk_interpretor", routeBuilder),
+		api.RouteLogLevel("/api/log_level", routeBuilder),
+	)
 	if err != nil {
-		sp.Logger.Warning("Error configuring Drunk Bishop: %v", err)
-		err = nil
-	}
-	api.RouteBrainFxxkInterpretor("/api/brain_fxxk_interpretor", routeBuilder)
-	err = api.ConfigureBrainFxxkInterpretor("/api/brain_fxxk_interpretor", openApiBuilder)
-	if err != nil {
-		sp.Logger.Warning("Error configuring BrainFxxk Interpretor: %v", err)
-		err = nil
+		return nil, err
 	}
 
 	if true {
 		sp.Logger.Warning("Exposing pprof at /api/pprof, this is not recommended in production")
-		api.RoutePProf("/api/pprof", routeBuilder)
-		err = api.ConfigurePProf("/api/pprof", openApiBuilder)
+		err = api.RoutePProf("/api/pprof", routeBuilder)
 		if err != nil {
-			sp.Logger.Warning("Error configuring PProf: %v", err)
-			err = nil
+			return nil, err
 		}
 	}
+	err = routeBuilder.Verify()
+	if err != nil {
+		return nil, err
+	}
 	server := http.Server{
-		Addr:    ":" + strconv.Itoa(sp.Configuration.Port),
-		Handler: newLoggingServeMux(sp.Logger, routeBuilder.Mux),
+		Handler: routeBuilder.Handler(),
+		// e.g. TLS handshake errors, which are client issues rather than server errors
+		ErrorLog: slog.NewLogLogger(logging.NewSlogHandler(sp.CreateLogger("http.Server")), slog.LevelWarn),
 	}
-	return &server
+	return &server, nil
 }
 
 func configureOpenApiBasics(reflector *openapi3.Reflector) {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"httpServer/logging"
+	"httpServer/services"
+	"strings"
+	"testing"
+)
+
+func TestReloadWarnsAboutTheChangesRequiringARestart(t *testing.T) {
+	output := &bytes.Buffer{}
+	factory := logging.NewLoggerFactory(logging.Information, nil)
+	_ = factory.SetSinks(logging.NewWriterSink(output, logging.Lowest, &logging.ConsoleFormatter{}))
+	sp := services.NewEmptyServiceProvider()
+	sp.AddLoggerFactory(factory)
+	sp.AddConfiguration(services.NewDefaultConfig())
+	configureConfigurationReload(sp, factory, services.NewAccessLog())
+
+	current := services.NewDefaultConfig()
+	current.Port = 9000
+	current.Tls.MinVersion = "1.3"
+	current.LogLevel = logging.Warning
+	if err := sp.UpdateConfiguration(current); err != nil {
+		t.Fatal(err)
+	}
+	warnings := output.String()
+	for _, path := range []string{"port", "tls.minVersion"} {
+		if !strings.Contains(warnings, "Configuration "+path+" changed, restart the application") {
+			t.Errorf("no restart warning for %s: %s", path, warnings)
+		}
+	}
+	if strings.Contains(warnings, "logLevel changed") {
+		t.Errorf("the log level requires a restart: %s", warnings)
+	}
+	if level, _ := factory.Levels(); level != logging.Warning {
+		t.Errorf("the log level is not applied: %s", level)
+	}
+}
+
+func TestMatchConfigurationPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"port", true},
+		{"portRange", false},
+		{"tls.certFile", true},
+		{"tls", false},
+		{"tlsMode", false},
+		{"reloadInterval", true},
+		{"logLevel", false},
+	}
+	for _, test := range tests {
+		if matched := matchConfigurationPath(test.path, restartRequiredConfigurations); matched != test.expected {
+			t.Errorf("%s: got %t", test.path, matched)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"httpServer/logging"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat selects how the AccessLogFormatter renders the requests.
+type AccessLogFormat string
+
+const (
+	// CombinedAccessLogFormat is the Apache combined log format:
+	// 127.0.0.1 - alice [15/Apr/2025:15:13:45 +0800] "GET /api/openapi HTTP/1.1" 200 2326 "https://example.com/" "curl/8.5.0"
+	CombinedAccessLogFormat AccessLogFormat = "combined"
+	// JsonAccessLogFormat is a JSON object per request, keyed by the AccessLogFields
+	JsonAccessLogFormat AccessLogFormat = "json"
+	// TemplateAccessLogFormat replaces the {field} placeholders of a template by the AccessLogFields
+	TemplateAccessLogFormat AccessLogFormat = "template"
+)
+
+// AccessLogFields are the fields of a request, in the order of the JSON objects:
+// the time, the remote IP and the authenticated user, the request line, the outcome, the duration in milliseconds and the request headers.
+var AccessLogFields = []string{"time", "remoteIp", "remoteUser", "method", "uri", "proto", "host", "status", "bytes", "duration", "referer", "userAgent", "requestId"}
+
+// ParseAccessLogFormat parses the name of an AccessLogFormat.
+func ParseAccessLogFormat(s string) (AccessLogFormat, error) {
+	switch AccessLogFormat(s) {
+	case CombinedAccessLogFormat, JsonAccessLogFormat, TemplateAccessLogFormat:
+		return AccessLogFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown access log format: %s", s)
+	}
+}
+
+//goland:noinspection GoMixedReceiverTypes
+func (f *AccessLogFormat) UnmarshalJSON(bytes []byte) error {
+	var s string
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return err
+	}
+	format, err := ParseAccessLogFormat(s)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// AccessLogFormatter is the logging.IFormatter of the access log sinks, it renders the fields written by AccessLog.
+type AccessLogFormatter struct {
+	format AccessLogFormat
+	// template is the parsed template, the odd parts are field names and the even ones literal text
+	template []string
+}
+
+// NewAccessLogFormatter returns the formatter of format, template is only used by TemplateAccessLogFormat.
+func NewAccessLogFormatter(format AccessLogFormat, template string) (*AccessLogFormatter, error) {
+	formatter := &AccessLogFormatter{format: format}
+	if format == TemplateAccessLogFormat {
+		parts, err := parseAccessLogTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		formatter.template = parts
+	}
+	return formatter, nil
+}
+
+// parseAccessLogTemplate splits template around its {field} placeholders, the field names must be AccessLogFields.
+func parseAccessLogTemplate(template string) ([]string, error) {
+	if template == "" {
+		return nil, errors.New("the template is empty")
+	}
+	var parts []string
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			return append(parts, template), nil
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder: %s", template[start:])
+		}
+		name := template[start+1 : start+end]
+		if !slices.Contains(AccessLogFields, name) {
+			return nil, fmt.Errorf("unknown field {%s}, expected one of %s", name, strings.Join(AccessLogFields, ", "))
+		}
+		parts = append(parts, template[:start], name)
+		template = template[start+end+1:]
+	}
+}
+
+func (f *AccessLogFormatter) Format(entry *logging.Entry) []byte {
+	values := make(map[string]interface{}, len(entry.Fields)+1)
+	values["time"] = entry.Time
+	for _, field := range entry.Fields {
+		values[field.Key] = field.Value
+	}
+	switch f.format {
+	case JsonAccessLogFormat:
+		return f.formatJson(values)
+	case TemplateAccessLogFormat:
+		builder := strings.Builder{}
+		for i, part := range f.template {
+			if i%2 == 0 {
+				builder.WriteString(part)
+				continue
+			}
+			if t, ok := values[part].(time.Time); ok {
+				builder.WriteString(t.Format(time.RFC3339))
+				continue
+			}
+			builder.WriteString(fmt.Sprint(values[part]))
+		}
+		builder.WriteRune('\n')
+		return []byte(builder.String())
+	default:
+		return f.formatCombined(values)
+	}
+}
+
+func (f *AccessLogFormatter) formatJson(values map[string]interface{}) []byte {
+	builder := strings.Builder{}
+	builder.WriteRune('{')
+	for i, name := range AccessLogFields {
+		value := values[name]
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339Nano)
+		}
+		key, _ := json.Marshal(name)
+		text, err := json.Marshal(value)
+		if err != nil {
+			text, _ = json.Marshal(fmt.Sprint(value))
+		}
+		if i > 0 {
+			builder.WriteRune(',')
+		}
+		builder.Write(key)
+		builder.WriteRune(':')
+		builder.Write(text)
+	}
+	builder.WriteString("}\n")
+	return []byte(builder.String())
+}
+
+func (f *AccessLogFormatter) formatCombined(values map[string]interface{}) []byte {
+	text := func(name string) string {
+		value := fmt.Sprint(values[name])
+		if value == "" || value == "0" {
+			return "-"
+		}
+		return escapeAccessLogValue(value)
+	}
+	t, _ := values["time"].(time.Time)
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %v %s \"%s\" \"%s\"\n",
+		text("remoteIp"), text("remoteUser"), t.Format("02/Jan/2006:15:04:05 -0700"),
+		text("method"), text("uri"), text("proto"), values["status"], text("bytes"),
+		text("referer"), text("userAgent"))
+	return []byte(line)
+}
+
+// escapeAccessLogValue escapes the quotes, the backslashes and the control characters like Apache does.
+func escapeAccessLogValue(value string) string {
+	builder := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case c < ' ' || c == 0x7f:
+			builder.WriteString(fmt.Sprintf("\\x%02x", c))
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String()
+}
+
+// AccessLog writes a line per request to its own sinks, after the response is finished.
+type AccessLog struct {
+	mutex          sync.RWMutex
+	sinks          []logging.ISink
+	redactor       *logging.Redactor
+	trustedProxies []netip.Prefix
+	trustUnix      bool
+}
+
+// NewAccessLog returns a disabled AccessLog, see Configure.
+func NewAccessLog() *AccessLog {
+	return &AccessLog{}
+}
+
+// Configure applies the AccessLog, the LogQueue and the LogRedaction of config, the previous sinks are closed.
+// The current configuration is kept when config can't be applied.
+func (a *AccessLog) Configure(config *Configuration) error {
+	trustedProxies, trustUnix, err := parseTrustedProxies(config.AccessLog.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	redactor, err := config.LogRedaction.CreateRedactor()
+	if err != nil {
+		return err
+	}
+	sinks, err := config.CreateAccessLogSinks()
+	if err != nil {
+		return err
+	}
+	a.mutex.Lock()
+	previous := a.sinks
+	a.sinks = sinks
+	a.redactor = redactor
+	a.trustedProxies = trustedProxies
+	a.trustUnix = trustUnix
+	a.mutex.Unlock()
+	return closeAccessLogSinks(previous)
+}
+
+// Close closes the sinks, the requests are not logged anymore.
+func (a *AccessLog) Close() error {
+	a.mutex.Lock()
+	previous := a.sinks
+	a.sinks = nil
+	a.mutex.Unlock()
+	return closeAccessLogSinks(previous)
+}
+
+func closeAccessLogSinks(sinks []logging.ISink) error {
+	var err error
+	for _, sink := range sinks {
+		err = errors.Join(err, sink.Close())
+	}
+	return err
+}
+
+// Middleware logs the requests served by next. It must run inside RequestScopeMiddleware to log the request id and the authenticated user.
+func (a *AccessLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mutex.RLock()
+		enabled := len(a.sinks) > 0
+		a.mutex.RUnlock()
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		recorder := &accessLogResponseWriter{ResponseWriter: w}
+		// the request is logged even when the handler panics, net/http recovers and closes the connection afterwards
+		defer func() {
+			recovered := recover()
+			if recovered != nil && recorder.status == 0 {
+				// nothing was sent, the client gets no response at all
+				recorder.status = http.StatusInternalServerError
+			}
+			a.write(r, recorder, start, time.Since(start))
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+func (a *AccessLog) write(r *http.Request, recorder *accessLogResponseWriter, start time.Time, duration time.Duration) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	remoteUser, requestId := "", ""
+	if scope := RequestScopeFromContext(r.Context()); scope != nil {
+		requestId = scope.RequestId
+		if principal := scope.Principal(); principal != nil {
+			remoteUser = principal.Subject
+		}
+	}
+	template := "%s %s %d"
+	entry := &logging.Entry{
+		Time:     start,
+		Level:    logging.Information,
+		Category: "http.Access",
+		Template: template,
+		Message:  fmt.Sprintf(template, r.Method, r.RequestURI, status),
+		Args:     []interface{}{r.Method, r.RequestURI, status},
+		Fields: []logging.Field{
+			{Key: "remoteIp", Value: a.remoteIp(r)},
+			{Key: "remoteUser", Value: remoteUser},
+			{Key: "method", Value: r.Method},
+			{Key: "uri", Value: r.RequestURI},
+			{Key: "proto", Value: r.Proto},
+			{Key: "host", Value: r.Host},
+			{Key: "status", Value: status},
+			{Key: "bytes", Value: recorder.bytes},
+			{Key: "duration", Value: float64(duration.Microseconds()) / 1000},
+			{Key: "referer", Value: r.Referer()},
+			{Key: "userAgent", Value: r.UserAgent()},
+			{Key: "requestId", Value: requestId},
+		},
+	}
+	if a.redactor != nil {
+		entry = a.redactor.Redact(entry)
+	}
+	for _, sink := range a.sinks {
+		if entry.Level < sink.Level() {
+			continue
+		}
+		err := sink.Write(entry)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error writing access log: %v\n", err)
+		}
+	}
+}
+
+// remoteIp returns the IP of the client. When the connection comes from a trusted proxy, the X-Forwarded-For addresses
+// are walked from the closest one and the first address which isn't a trusted proxy is the client, X-Real-Ip is used without X-Forwarded-For.
+func (a *AccessLog) remoteIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err == nil {
+		if !a.isTrustedProxy(addr) {
+			return host
+		}
+	} else if !a.trustUnix {
+		// Unix sockets have no IP, the remote address is empty or "@"
+		return host
+	}
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(header, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(address))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(forwarded[i])
+		if err != nil {
+			// a garbled hop can't be trusted to have forwarded the real client
+			return forwarded[i]
+		}
+		if i == 0 || !a.isTrustedProxy(addr) {
+			return addr.String()
+		}
+	}
+	if realIp, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-Ip"))); err == nil {
+		return realIp.String()
+	}
+	return host
+}
+
+func (a *AccessLog) isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range a.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses the IP addresses and CIDR ranges of proxies, "unix" trusts the connections of the Unix sockets.
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, bool, error) {
+	var prefixes []netip.Prefix
+	trustUnix := false
+	for _, proxy := range proxies {
+		if proxy == "unix" {
+			trustUnix = true
+			continue
+		}
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, false, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, false, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, trustUnix, nil
+}
+
+// accessLogResponseWriter records the status code and the size of the response body.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogResponseWriter) WriteHeader(statusCode int) {
+	// the informational responses are followed by the final one, except the protocol switches
+	if w.status == 0 && (statusCode >= 200 || statusCode == http.StatusSwitchingProtocols) {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *accessLogResponseWriter) Write(bytes []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(bytes)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps the responses streamed by the handlers checking for http.Flusher.
+func (w *accessLogResponseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *accessLogResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the features of the underlying ResponseWriter.
+func (w *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"httpServer/logging"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAccessLog returns an AccessLog writing to output with formatter and trusting proxies.
+func newTestAccessLog(t *testing.T, output *bytes.Buffer, formatter *AccessLogFormatter, proxies ...string) *AccessLog {
+	t.Helper()
+	trustedProxies, trustUnix, err := parseTrustedProxies(proxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AccessLog{
+		sinks:          []logging.ISink{logging.NewWriterSink(output, logging.Lowest, formatter)},
+		trustedProxies: trustedProxies,
+		trustUnix:      trustUnix,
+	}
+}
+
+func TestAccessLogRemoteIp(t *testing.T) {
+	tests := []struct {
+		name         string
+		proxies      []string
+		remoteAddr   string
+		forwardedFor []string
+		realIp       string
+		expectedIp   string
+	}{
+		{"no proxy", nil, "192.0.2.1:1234", []string{"198.51.100.1"}, "198.51.100.2", "192.0.2.1"},
+		{"untrusted proxy", []string{"10.0.0.0/8"}, "192.0.2.1:1234", []string{"198.51.100.1"}, "", "192.0.2.1"},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.1:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"proxy chain", []string{"10.0.0.0/8", "192.0.2.1"}, "10.0.0.1:1234", []string{"203.0.113.9, 198.51.100.1", "192.0.2.1, 10.0.0.2"}, "", "198.51.100.1"},
+		{"spoofed first hop", []string{"10.0.0.1"}, "10.0.0.1:1234", []string{"203.0.113.9, 198.51.100.1"}, "", "198.51.100.1"},
+		{"only proxies", []string{"10.0.0.0/8"}, "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"garbled hop", []string{"10.0.0.1"}, "10.0.0.1:1234", []string{"198.51.100.1, nonsense"}, "", "nonsense"},
+		{"real ip", []string{"10.0.0.1"}, "10.0.0.1:1234", nil, "198.51.100.2", "198.51.100.2"},
+		{"invalid real ip", []string{"10.0.0.1"}, "10.0.0.1:1234", nil, "nonsense", "10.0.0.1"},
+		{"mapped ipv4", []string{"10.0.0.1"}, "[::ffff:10.0.0.1]:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"ipv6", []string{"2001:db8::/32"}, "[2001:db8::1]:1234", []string{"2001:db9::1"}, "", "2001:db9::1"},
+		{"untrusted unix socket", []string{"10.0.0.1"}, "@", []string{"198.51.100.1"}, "", "@"},
+		{"trusted unix socket", []string{"unix"}, "@", []string{"198.51.100.1"}, "", "198.51.100.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			accessLog := newTestAccessLog(t, &bytes.Buffer{}, &AccessLogFormatter{}, test.proxies...)
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = test.remoteAddr
+			for _, header := range test.forwardedFor {
+				request.Header.Add("X-Forwarded-For", header)
+			}
+			if test.realIp != "" {
+				request.Header.Set("X-Real-Ip", test.realIp)
+			}
+			if ip := accessLog.remoteIp(request); ip != test.expectedIp {
+				t.Errorf("got %s, want %s", ip, test.expectedIp)
+			}
+		})
+	}
+}
+
+// serveLogged serves a request through the Middleware of accessLog and returns the logged line.
+func serveLogged(t *testing.T, accessLog *AccessLog, output *bytes.Buffer, handler http.HandlerFunc) string {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, "/path?q=\"1\"", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	request.Header.Set("Referer", "https://example.com/")
+	request.Header.Set("User-Agent", "test")
+	accessLog.Middleware(handler).ServeHTTP(httptest.NewRecorder(), request)
+	return output.String()
+}
+
+func teapot(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+	_, _ = w.Write([]byte("short and stout"))
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	t.Run("combined", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		line := serveLogged(t, newTestAccessLog(t, output, &AccessLogFormatter{format: CombinedAccessLogFormat}), output, teapot)
+		prefix, suffix, found := strings.Cut(line, "] ")
+		if !found || !strings.HasPrefix(prefix, "192.0.2.1 - - [") {
+			t.Fatalf("got %q", line)
+		}
+		if _, err := time.Parse("02/Jan/2006:15:04:05 -0700", strings.TrimPrefix(prefix, "192.0.2.1 - - [")); err != nil {
+			t.Errorf("bad time: %v", err)
+		}
+		expected := `"GET /path?q=\"1\" HTTP/1.1" 418 15 "https://example.com/" "test"` + "\n"
+		if suffix != expected {
+			t.Errorf("got %q, want %q", suffix, expected)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		line := serveLogged(t, newTestAccessLog(t, output, &AccessLogFormatter{format: JsonAccessLogFormat}), output, teapot)
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("got %q: %v", line, err)
+		}
+		expected := map[string]interface{}{"remoteIp": "192.0.2.1", "method": "GET", "uri": `/path?q="1"`, "status": 418.0, "bytes": 15.0, "userAgent": "test"}
+		for key, value := range expected {
+			if fields[key] != value {
+				t.Errorf("%s is %v, want %v", key, fields[key], value)
+			}
+		}
+		if len(fields) != len(AccessLogFields) {
+			t.Errorf("got the fields %v", fields)
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		formatter, err := NewAccessLogFormatter(TemplateAccessLogFormat, "{remoteIp} {method} {uri} -> {status} ({bytes} bytes)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := &bytes.Buffer{}
+		line := serveLogged(t, newTestAccessLog(t, output, formatter), output, teapot)
+		if expected := "192.0.2.1 GET /path?q=\"1\" -> 418 (15 bytes)\n"; line != expected {
+			t.Errorf("got %q, want %q", line, expected)
+		}
+	})
+}
+
+func TestAccessLogTemplateErrors(t *testing.T) {
+	for _, template := range []string{"", "{method", "{nope}"} {
+		if _, err := NewAccessLogFormatter(TemplateAccessLogFormat, template); err == nil {
+			t.Errorf("the template %q is accepted", template)
+		}
+	}
+}
+
+func TestAccessLogLogsThePanics(t *testing.T) {
+	output := &bytes.Buffer{}
+	accessLog := newTestAccessLog(t, output, &AccessLogFormatter{format: JsonAccessLogFormat})
+	defer func() {
+		if recovered := recover(); recovered != "boom" {
+			t.Errorf("the panic is not propagated, got %v", recovered)
+		}
+		if !strings.Contains(output.String(), `"status":500`) {
+			t.Errorf("got %s", output.String())
+		}
+	}()
+	serveLogged(t, accessLog, output, func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+}
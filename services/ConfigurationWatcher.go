@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"httpServer/logging"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ConfigurationWatcher reloads the Configuration when its file changes or when the process receives SIGHUP,
+// and hands it to ServiceProvider.UpdateConfiguration.
+type ConfigurationWatcher struct {
+	path     string
+	interval time.Duration
+	load     func() (*Configuration, error)
+	sp       *ServiceProvider
+	logger   logging.ILogger
+
+	modTime time.Time
+	size    int64
+}
+
+// NewConfigurationWatcher creates a watcher polling path every interval, 0 disables the polling so only SIGHUP triggers a reload.
+// load builds the new Configuration, usually from the same layers (file, environment, command line) as the startup one.
+func NewConfigurationWatcher(path string, interval time.Duration, load func() (*Configuration, error)) *ConfigurationWatcher {
+	return &ConfigurationWatcher{
+		path:     path,
+		interval: interval,
+		load:     load,
+	}
+}
+
+func (w *ConfigurationWatcher) Init(provider *ServiceProvider) error {
+	w.sp = provider
+	w.logger = provider.CreateLogger("services.ConfigurationWatcher")
+	w.modTime, w.size = w.stat()
+	return nil
+}
+
+// Run watches until ctx is done, a failed reload is logged and the current Configuration is kept.
+func (w *ConfigurationWatcher) Run(ctx context.Context) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+			w.logger.Information("SIGHUP received, reloading configuration")
+			w.modTime, w.size = w.stat()
+			w.reload()
+		case <-tick:
+			modTime, size := w.stat()
+			if modTime.Equal(w.modTime) && size == w.size {
+				continue
+			}
+			w.modTime, w.size = modTime, size
+			w.logger.Information("Config file %s changed, reloading configuration", w.path)
+			w.reload()
+		}
+	}
+}
+
+// stat returns zero values when the file doesn't exist, so creating or deleting it is a change too.
+func (w *ConfigurationWatcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+func (w *ConfigurationWatcher) reload() {
+	config, err := w.load()
+	if err != nil {
+		w.logger.Warning("Error reloading configuration, keeping the current one: %v", err)
+		return
+	}
+	previous := w.sp.Configuration()
+	err = w.sp.UpdateConfiguration(config)
+	if err != nil {
+		w.logger.Warning("Reloaded configuration rejected, keeping the current one: %v", err)
+		return
+	}
+	changed := ChangedConfigurationPaths(previous, config)
+	if len(changed) == 0 {
+		w.logger.Information("Configuration reloaded, nothing changed")
+		return
+	}
+	w.logger.Information("Configuration reloaded, changed %v", changed)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// configurationChange is a call to a subscriber of OnConfigurationChanged.
+type configurationChange struct {
+	previous *Configuration
+	current  *Configuration
+}
+
+// newWatchedServiceProvider returns a provider with the default configuration and the changes notified to its subscribers.
+func newWatchedServiceProvider() (*ServiceProvider, *[]configurationChange) {
+	sp := newTestServiceProvider()
+	sp.AddConfiguration(NewDefaultConfig())
+	changes := &[]configurationChange{}
+	sp.OnConfigurationChanged(func(previous *Configuration, current *Configuration) {
+		*changes = append(*changes, configurationChange{previous, current})
+	})
+	return sp, changes
+}
+
+// newTestWatcher returns an initialized watcher of sp loading the configurations returned by load, it never polls.
+func newTestWatcher(t *testing.T, sp *ServiceProvider, load func() (*Configuration, error)) *ConfigurationWatcher {
+	t.Helper()
+	watcher := NewConfigurationWatcher(filepath.Join(t.TempDir(), "config.json"), 0, load)
+	if err := watcher.Init(sp); err != nil {
+		t.Fatal(err)
+	}
+	return watcher
+}
+
+func TestReloadKeepsTheCurrentConfigurationOnErrors(t *testing.T) {
+	sp, changes := newWatchedServiceProvider()
+	current := sp.Configuration()
+
+	invalid := NewDefaultConfig()
+	invalid.Port = 9000
+	invalid.JwtSecret = "short"
+	newTestWatcher(t, sp, func() (*Configuration, error) { return invalid, nil }).reload()
+	if sp.Configuration() != current {
+		t.Error("the invalid configuration is swapped in")
+	}
+
+	newTestWatcher(t, sp, func() (*Configuration, error) { return nil, errors.New("unreadable") }).reload()
+	if sp.Configuration() != current {
+		t.Error("the configuration is replaced when the reload fails")
+	}
+	if len(*changes) != 0 {
+		t.Errorf("the subscribers are notified of %d changes", len(*changes))
+	}
+	var validateError *ConfigurationValidateError
+	if err := sp.UpdateConfiguration(invalid); !errors.As(err, &validateError) || len(validateError.Errors["jwtSecret"]) == 0 {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestSubscribersAreOnlyNotifiedOfRealChanges(t *testing.T) {
+	sp, changes := newWatchedServiceProvider()
+	initial := sp.Configuration()
+	next := NewDefaultConfig()
+	watcher := newTestWatcher(t, sp, func() (*Configuration, error) { return next, nil })
+
+	watcher.reload()
+	if len(*changes) != 0 || sp.Configuration() != initial {
+		t.Errorf("an identical configuration is notified %d times", len(*changes))
+	}
+
+	next = NewDefaultConfig()
+	next.Port = 9000
+	watcher.reload()
+	if len(*changes) != 1 || (*changes)[0].previous != initial || (*changes)[0].current != next || sp.Configuration() != next {
+		t.Fatalf("got the changes %v", *changes)
+	}
+	if changed := ChangedConfigurationPaths((*changes)[0].previous, (*changes)[0].current); !slices.Equal(changed, []string{"port"}) {
+		t.Errorf("changed %v", changed)
+	}
+}
+
+func TestWatcherReloadsTheChangedFile(t *testing.T) {
+	sp, _ := newWatchedServiceProvider()
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(port int) {
+		if err := os.WriteFile(path, []byte(`{"port": `+strconv.Itoa(port)+`}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(9000)
+	watcher := NewConfigurationWatcher(path, 5*time.Millisecond, func() (*Configuration, error) {
+		loader := NewConfigurationLoader()
+		return loader.Configuration(), loader.ApplyJsonFile(path)
+	})
+	if err := watcher.Init(sp); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = watcher.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the size changes, so the change is seen whatever the resolution of the modification times
+	writeConfig(10000)
+	deadline := time.Now().Add(5 * time.Second)
+	for sp.Configuration().Port != 10000 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the port is still %d", sp.Configuration().Port)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
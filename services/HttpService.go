@@ -3,78 +3,271 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"httpServer/logging"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
 type HttpService struct {
-	server   *http.Server
-	initFunc func(*http.Server, *ServiceProvider) error
-	sp       *ServiceProvider
+	server    *http.Server
+	addresses []string
+	initFunc  func(*http.Server, *ServiceProvider) error
+	sp        *ServiceProvider
+	logger    logging.ILogger
+
+	// certificates is not nil when TLS is enabled
+	certificates   *certificateReloader
+	reloadInterval time.Duration
+	// redirectServer serves redirectAddresses with plain HTTP and redirects every request to HTTPS
+	redirectServer    *http.Server
+	redirectAddresses []string
 }
 
-func NewHttpService(server *http.Server, initFunc func(httpServer *http.Server, serviceProvider *ServiceProvider) error) *HttpService {
+// NewHttpService creates a service serving server on every address, see ParseListenAddress for the address format.
+func NewHttpService(server *http.Server, addresses []string, initFunc func(httpServer *http.Server, serviceProvider *ServiceProvider) error) *HttpService {
 	return &HttpService{
-		server:   server,
-		initFunc: initFunc,
+		server:    server,
+		addresses: addresses,
+		initFunc:  initFunc,
+	}
+}
+
+// ParseListenAddress splits a listen address into the network and address accepted by net.Listen.
+// "unix:/path/to/socket" is a Unix domain socket, anything else is a TCP "host:port" where host can be an IPv6 literal in brackets or empty for all interfaces.
+func ParseListenAddress(address string) (network string, addr string, err error) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if path == "" {
+			return "", "", fmt.Errorf("listen address %q has no socket path", address)
+		}
+		return "unix", path, nil
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", err
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 0 || portNumber > 65535 {
+		return "", "", fmt.Errorf("listen address %q has an invalid port", address)
 	}
+	return "tcp", address, nil
+}
+
+// UseTls makes the service serve HTTPS on every address, and plain HTTP redirects on config.RedirectListen.
+// The certificate files are loaded immediately so a misconfiguration is reported before the service starts.
+func (h *HttpService) UseTls(config *TlsConfiguration) error {
+	certificates, err := newCertificateReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := newTlsConfig(config, certificates)
+	if err != nil {
+		return err
+	}
+	h.server.TLSConfig = tlsConfig
+	h.certificates = certificates
+	h.reloadInterval = time.Duration(config.ReloadInterval) * time.Second
+	if len(config.RedirectListen) > 0 {
+		h.redirectServer = &http.Server{
+			Handler:           newHttpsRedirectHandler(h.httpsPort()),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		h.redirectAddresses = config.RedirectListen
+	}
+	return nil
+}
+
+// httpsPort returns the port of the first TCP address, which is the target of the HTTPS redirects.
+func (h *HttpService) httpsPort() string {
+	for _, address := range h.addresses {
+		network, addr, err := ParseListenAddress(address)
+		if err != nil || network != "tcp" {
+			continue
+		}
+		_, port, _ := net.SplitHostPort(addr)
+		return port
+	}
+	return ""
+}
+
+// newHttpsRedirectHandler redirects every request to the same url with https scheme, httpsPort is omitted when it is the default one.
+func newHttpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), httpsPort)
+		} else if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			// IPv6 literal
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
 }
 
 func (h *HttpService) Init(provider *ServiceProvider) error {
 	h.sp = provider
+	h.logger = provider.CreateLogger("services.HttpService")
 	if h.initFunc != nil {
 		return h.initFunc(h.server, provider)
 	}
 	return nil
 }
 
-// Run starts the http server and waits for it to be stopped.
-// This returns the error of net/http.Server.ListenAndServe(), so when error is not nil, it can be ErrServerClosed or others.
+// listen opens every address, the listeners already opened are closed when one of them fails.
+func listen(addresses []string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		network, addr, err := ParseListenAddress(address)
+		if err == nil && network == "unix" {
+			err = removeStaleSocket(addr)
+		}
+		var listener net.Listener
+		if err == nil {
+			listener, err = net.Listen(network, addr)
+		}
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, errors.New("no listen address configured")
+	}
+	return listeners, nil
+}
+
+// removeStaleSocket removes the socket file left by a previous process which was not stopped gracefully.
+// A socket still accepting connections belongs to a running process and is kept.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	connection, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = connection.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("checking whether %s is in use: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// Run starts the http server on every address and waits for it to be stopped.
+// This returns the error of net/http.Server.Serve(), so when error is not nil, it can be ErrServerClosed or others.
 func (h *HttpService) Run(ctx context.Context) error {
+	listeners, err := listen(h.addresses)
+	if err != nil {
+		return err
+	}
+	var redirectListeners []net.Listener
+	if h.redirectServer != nil {
+		redirectListeners, err = listen(h.redirectAddresses)
+		if err != nil {
+			for _, listener := range listeners {
+				_ = listener.Close()
+			}
+			return err
+		}
+	}
+	if h.certificates != nil && h.reloadInterval > 0 {
+		go h.certificates.watch(ctx, h.reloadInterval, h.logger)
+	}
 	// register ctx for shutdown
-	var stoppingCtx context.Context
-	var stoppingCancel context.CancelFunc
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
-		stoppingCtx, stoppingCancel = context.WithTimeout(context.Background(), 30*time.Second)
+		stoppingCtx, stoppingCancel := context.WithTimeout(context.Background(), 30*time.Second)
 		go func() {
 			time.Sleep(time.Millisecond * 10) // give time to the shutdown to start
 			select {
 			case <-stoppingCtx.Done():
-				break
+				return
 			default:
 			}
 			time.Sleep(time.Second * 2)
 			select {
 			case <-stoppingCtx.Done():
-				break
+				return
 			default:
-				h.sp.Logger.Information("Waiting for server to shutdown")
+				h.logger.Information("Waiting for server to shutdown")
 			}
 			time.Sleep(time.Second * 10)
 			select {
 			case <-stoppingCtx.Done():
-				break
+				return
 			default:
-				h.sp.Logger.Warning("Service is still running after 10 seconds, waiting for another 20 seconds before force shutdown")
+				h.logger.Warning("Service is still running after 10 seconds, waiting for another 20 seconds before force shutdown")
 			}
 		}()
+		if h.redirectServer != nil {
+			_ = h.redirectServer.Shutdown(stoppingCtx)
+		}
 		err := h.server.Shutdown(stoppingCtx)
 		stoppingCancel()
 		switch {
 		case err != nil && errors.Is(err, context.DeadlineExceeded):
-			h.sp.Logger.Warning("Deadline exceeded, service is killed")
+			h.logger.Warning("Deadline exceeded, service is killed")
 			break
 		case err != nil && errors.Is(err, http.ErrServerClosed):
 		case err == nil:
-			h.sp.Logger.Information("Service stopped")
+			h.logger.Information("Service stopped")
 			break
 		case err != nil:
-			h.sp.Logger.Warning("Error stopping service: %v", err)
+			h.logger.Warning("Error stopping service: %v", err)
 			break
 		}
 	}()
-	serverErr := h.server.ListenAndServe()
-	<-stoppingCtx.Done()
+	serverErrs := make(chan error, len(listeners)+len(redirectListeners))
+	for _, listener := range listeners {
+		if h.certificates != nil {
+			h.logger.Information("Listening on %s://%s with TLS", listener.Addr().Network(), listener.Addr().String())
+			go func() {
+				// the certificate is provided by TLSConfig.GetCertificate
+				serverErrs <- h.server.ServeTLS(listener, "", "")
+			}()
+		} else {
+			h.logger.Information("Listening on %s://%s", listener.Addr().Network(), listener.Addr().String())
+			go func() {
+				serverErrs <- h.server.Serve(listener)
+			}()
+		}
+	}
+	for _, listener := range redirectListeners {
+		h.logger.Information("Redirecting %s://%s to HTTPS", listener.Addr().Network(), listener.Addr().String())
+		go func() {
+			serverErrs <- h.redirectServer.Serve(listener)
+		}()
+	}
+	serverErr := <-serverErrs
+	if errors.Is(serverErr, http.ErrServerClosed) {
+		// Shutdown has been called, wait for the active connections to finish
+		<-stopped
+		return serverErr
+	}
+	// one listener failed, stop serving on the others
+	_ = h.server.Close()
+	if h.redirectServer != nil {
+		_ = h.redirectServer.Close()
+	}
 	return serverErr
 }
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"httpServer/logging"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate is a key pair generated for a test, written as PEM files.
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certFile    string
+	keyFile     string
+}
+
+var testSerial int64
+
+// newTestCertificate writes a certificate for localhost signed by parent, or a self-signed CA when parent is nil.
+func newTestCertificate(t *testing.T, dir string, name string, parent *testCertificate, usage x509.ExtKeyUsage) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.DNSNames = []string{"localhost"}
+		template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certFile:    filepath.Join(dir, name+".pem"),
+		keyFile:     filepath.Join(dir, name+".key"),
+	}
+	writeTestFile(t, generated.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeTestFile(t, generated.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	return generated
+}
+
+// writeTestFile writes data to path with a modification time after the previous one, the reloader compares them.
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil && !modTime.After(info.ModTime()) {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testCertificate) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.certificate)
+	return pool
+}
+
+func newTestServiceProvider() *ServiceProvider {
+	sp := NewEmptyServiceProvider()
+	factory := logging.NewLoggerFactory(logging.Warning, nil)
+	_ = factory.SetSinks(logging.NewWriterSink(io.Discard, logging.Lowest, &logging.LogfmtFormatter{}))
+	sp.AddLoggerFactory(factory)
+	return sp
+}
+
+// startTlsService runs an HttpService answering "ok" over TLS on a Unix socket, it is stopped at the end of the test.
+func startTlsService(t *testing.T, config *TlsConfiguration) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "https.sock")
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})}
+	service := NewHttpService(server, []string{"unix:" + socket}, nil)
+	if err := service.Init(newTestServiceProvider()); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.UseTls(config); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- service.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-stopped; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Run returned %v", err)
+		}
+	})
+	for i := 0; ; i++ {
+		connection, err := net.Dial("unix", socket)
+		if err == nil {
+			_ = connection.Close()
+			return socket
+		}
+		if i == 100 {
+			t.Fatalf("the service is not listening: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// getOverTls requests the service listening on socket, verifying its certificate with roots and presenting clientCertificate when not nil.
+func getOverTls(socket string, roots *x509.CertPool, clientCertificate *testCertificate) (string, error) {
+	tlsConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if clientCertificate != nil {
+		pair, err := tls.LoadX509KeyPair(clientCertificate.certFile, clientCertificate.keyFile)
+		if err != nil {
+			return "", err
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+		TLSClientConfig: tlsConfig,
+	}}
+	defer client.CloseIdleConnections()
+	response, err := client.Get("https://localhost/")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	return string(body), err
+}
+
+func TestHttpServiceServesTls(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, 0)
+	server := newTestCertificate(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	socket := startTlsService(t, &TlsConfiguration{CertFile: server.certFile, KeyFile: server.keyFile, MinVersion: "1.2"})
+
+	body, err := getOverTls(socket, ca.pool(), nil)
+	if err != nil || body != "ok" {
+		t.Fatalf("got %q, %v", body, err)
+	}
+	_, err = getOverTls(socket, x509.NewCertPool(), nil)
+	if err == nil {
+		t.Error("the certificate is trusted without its CA")
+	}
+}
+
+func TestHttpServiceVerifiesClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, 0)
+	server := newTestCertificate(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	client := newTestCertificate(t, dir, "client", ca, x509.ExtKeyUsageClientAuth)
+	otherCa := newTestCertificate(t, dir, "otherCa", nil, 0)
+	otherClient := newTestCertificate(t, dir, "otherClient", otherCa, x509.ExtKeyUsageClientAuth)
+	socket := startTlsService(t, &TlsConfiguration{CertFile: server.certFile, KeyFile: server.keyFile, ClientCaFile: ca.certFile})
+
+	body, err := getOverTls(socket, ca.pool(), client)
+	if err != nil || body != "ok" {
+		t.Fatalf("the client certificate is rejected: %q, %v", body, err)
+	}
+	if _, err = getOverTls(socket, ca.pool(), nil); err == nil {
+		t.Error("a client without certificate is accepted")
+	}
+	if _, err = getOverTls(socket, ca.pool(), otherClient); err == nil {
+		t.Error("a client certificate of another CA is accepted")
+	}
+}
+
+func TestHttpsRedirectHandler(t *testing.T) {
+	tests := []struct {
+		httpsPort string
+		host      string
+		location  string
+	}{
+		{"443", "example.com", "https://example.com/path?q=1"},
+		{"443", "example.com:80", "https://example.com/path?q=1"},
+		{"", "example.com:8080", "https://example.com/path?q=1"},
+		{"8443", "example.com", "https://example.com:8443/path?q=1"},
+		{"8443", "example.com:8080", "https://example.com:8443/path?q=1"},
+		{"443", "[::1]", "https://[::1]/path?q=1"},
+		{"443", "[::1]:80", "https://[::1]/path?q=1"},
+		{"8443", "[::1]", "https://[::1]:8443/path?q=1"},
+		{"8443", "[2001:db8::1]:8080", "https://[2001:db8::1]:8443/path?q=1"},
+	}
+	for _, test := range tests {
+		t.Run(test.httpsPort+" "+test.host, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/path?q=1", nil)
+			request.Host = test.host
+			recorder := httptest.NewRecorder()
+			newHttpsRedirectHandler(test.httpsPort).ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusPermanentRedirect {
+				t.Errorf("got status %d", recorder.Code)
+			}
+			if location := recorder.Header().Get("Location"); location != test.location {
+				t.Errorf("got Location %q, want %q", location, test.location)
+			}
+		})
+	}
+}
+
+func TestHttpServiceRedirectsToTheFirstTcpPort(t *testing.T) {
+	service := NewHttpService(&http.Server{}, []string{"unix:/tmp/a.sock", "[::1]:8443", "127.0.0.1:9443"}, nil)
+	if port := service.httpsPort(); port != "8443" {
+		t.Errorf("got port %q", port)
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+	if err := removeStaleSocket(filepath.Join(dir, "missing.sock")); err != nil {
+		t.Errorf("a missing socket: %v", err)
+	}
+
+	regular := filepath.Join(dir, "regular")
+	writeTestFile(t, regular, []byte("data"))
+	if err := removeStaleSocket(regular); err == nil {
+		t.Error("a regular file is removed")
+	}
+
+	live := filepath.Join(dir, "live.sock")
+	listener, err := net.Listen("unix", live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err = removeStaleSocket(live); err == nil {
+		t.Error("the socket of a running server is removed")
+	}
+	if _, err = os.Stat(live); err != nil {
+		t.Errorf("the socket of a running server is gone: %v", err)
+	}
+
+	stale := filepath.Join(dir, "stale.sock")
+	staleListener, err := net.ListenUnix("unix", &net.UnixAddr{Name: stale, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// like a process which was killed, the file is left behind
+	staleListener.SetUnlinkOnClose(false)
+	_ = staleListener.Close()
+	if err = removeStaleSocket(stale); err != nil {
+		t.Errorf("a stale socket: %v", err)
+	}
+	if _, err = os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("the stale socket is kept: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/golang-jwt/jwt/v5"
+	"httpServer/logging"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RequestIdHeader is read to reuse the request id given by a proxy, and written on every response.
+const RequestIdHeader = "X-Request-Id"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Subject identifies the caller, e.g. the "sub" claim of a JWT
+	Subject string
+	Claims  jwt.Claims
+}
+
+// RequestScope is the ServiceScope of an incoming request, it is closed when the response is finished.
+type RequestScope struct {
+	*ServiceScope
+	// RequestId identifies the request in the logs and in the RequestIdHeader of the response
+	RequestId string
+	// Logger is the logger of the request, every message carries the RequestId and the remote address
+	Logger logging.ILogger
+	// Request is the incoming request
+	Request *http.Request
+
+	remoteAddr string
+
+	principalMutex sync.RWMutex
+	principal      *Principal
+}
+
+// HasRole reports whether the "role" claim of the principal, or one of its "roles" claim, is role.
+func (p *Principal) HasRole(role string) bool {
+	claims, ok := p.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	if claimed, ok := claims["role"].(string); ok && claimed == role {
+		return true
+	}
+	roles, _ := claims["roles"].([]any)
+	for _, claimed := range roles {
+		if claimed == role {
+			return true
+		}
+	}
+	return false
+}
+
+type requestScopeKey struct{}
+
+// Principal returns the authenticated caller, nil for anonymous requests.
+func (s *RequestScope) Principal() *Principal {
+	s.principalMutex.RLock()
+	defer s.principalMutex.RUnlock()
+	return s.principal
+}
+
+// SetPrincipal is called by the authentication once the caller is verified.
+func (s *RequestScope) SetPrincipal(principal *Principal) {
+	s.principalMutex.Lock()
+	defer s.principalMutex.Unlock()
+	s.principal = principal
+}
+
+// CreateLogger returns a logger of category carrying the request fields, see Logger.
+func (s *RequestScope) CreateLogger(category string) logging.ILogger {
+	return s.sp.CreateLogger(category).
+		With("requestId", s.RequestId).
+		With("remoteAddr", s.remoteAddr)
+}
+
+// RequestScopeFromContext returns the RequestScope of the request context, nil when the request didn't go through RequestScopeMiddleware.
+func RequestScopeFromContext(ctx context.Context) *RequestScope {
+	scope, _ := ctx.Value(requestScopeKey{}).(*RequestScope)
+	return scope
+}
+
+// RequestScopeMiddleware creates a RequestScope for every request, attaches it to the request context and closes it once next returned.
+func (sp *ServiceProvider) RequestScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIdHeader)
+		if !isValidRequestId(requestId) {
+			requestId = newRequestId()
+		}
+		w.Header().Set(RequestIdHeader, requestId)
+		scope := &RequestScope{
+			ServiceScope: sp.CreateScope(),
+			RequestId:    requestId,
+			remoteAddr:   r.RemoteAddr,
+		}
+		scope.Logger = scope.CreateLogger("http.Request")
+		defer func() {
+			err := scope.Close()
+			if err != nil {
+				scope.Logger.Warning("Error releasing request resources: %v", err)
+			}
+		}()
+		scope.Request = r.WithContext(context.WithValue(r.Context(), requestScopeKey{}, scope))
+		next.ServeHTTP(w, scope.Request)
+	})
+}
+
+func newRequestId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// isValidRequestId accepts the ids given by clients or proxies only when they are short and made of safe characters.
+func isValidRequestId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveScoped serves a request with the request id header through RequestScopeMiddleware, handler gets the scope.
+func serveScoped(sp *ServiceProvider, requestId string, handler func(scope *RequestScope)) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if requestId != "" {
+		request.Header.Set(RequestIdHeader, requestId)
+	}
+	recorder := httptest.NewRecorder()
+	sp.RequestScopeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(RequestScopeFromContext(r.Context()))
+	})).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRequestScopeReusesTheValidRequestIds(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestId string
+		reused    bool
+	}{
+		{"valid", "abc-123_X.y:z", true},
+		{"longest", strings.Repeat("a", 128), true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", 129), false},
+		{"space", "abc 123", false},
+		{"header injection", "abc\r\nSet-Cookie: a=b", false},
+		{"non ascii", "abcé", false},
+	}
+	sp := newTestServiceProvider()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var scopeId string
+			response := serveScoped(sp, test.requestId, func(scope *RequestScope) {
+				scopeId = scope.RequestId
+			})
+			echoed := response.Header().Get(RequestIdHeader)
+			if echoed != scopeId {
+				t.Errorf("the response has the id %q, the scope %q", echoed, scopeId)
+			}
+			if test.reused {
+				if scopeId != test.requestId {
+					t.Errorf("got %q", scopeId)
+				}
+				return
+			}
+			if _, err := hex.DecodeString(scopeId); err != nil || len(scopeId) != 32 {
+				t.Errorf("the id %q is not a new one", scopeId)
+			}
+		})
+	}
+
+	first := serveScoped(sp, "", func(*RequestScope) {}).Header().Get(RequestIdHeader)
+	if second := serveScoped(sp, "", func(*RequestScope) {}).Header().Get(RequestIdHeader); first == second {
+		t.Errorf("two requests have the id %s", first)
+	}
+}
+
+func TestRequestScopeClosesTheScopedServices(t *testing.T) {
+	sp := newTestServiceProvider()
+	repositoryFactory, calls := countingFactory[testRepository]()
+	Register(sp, Scoped, repositoryFactory)
+
+	var repositories []*testRepository
+	serveScoped(sp, "", func(scope *RequestScope) {
+		repositories = append(repositories, MustResolve[*testRepository](scope), MustResolve[*testRepository](scope))
+		if repositories[0].closed {
+			t.Error("the scoped service is closed during the request")
+		}
+	})
+	serveScoped(sp, "", func(scope *RequestScope) {
+		repositories = append(repositories, MustResolve[*testRepository](scope))
+	})
+	if calls.Load() != 2 || repositories[0] != repositories[1] || repositories[1] == repositories[2] {
+		t.Errorf("the scoped service is built %d times", calls.Load())
+	}
+	if !repositories[0].closed || !repositories[2].closed {
+		t.Error("the scoped services are not closed at the end of the requests")
+	}
+}
+
+func TestRequestScopeIsClosedWhenTheHandlerPanics(t *testing.T) {
+	sp := newTestServiceProvider()
+	repositoryFactory, _ := countingFactory[testRepository]()
+	Register(sp, Scoped, repositoryFactory)
+
+	var repository *testRepository
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Errorf("the panic is not propagated, got %v", recovered)
+			}
+		}()
+		serveScoped(sp, "", func(scope *RequestScope) {
+			repository = MustResolve[*testRepository](scope)
+			panic("boom")
+		})
+	}()
+	if repository == nil || !repository.closed {
+		t.Error("the scoped service is not closed after the panic")
+	}
+}
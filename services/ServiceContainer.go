@@ -0,0 +1,397 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ServiceLifetime tells how long an instance built by a registered factory is reused.
+type ServiceLifetime int
+
+const (
+	// Singleton instances are built once per ServiceProvider
+	Singleton ServiceLifetime = iota
+	// Transient instances are built on every Resolve
+	Transient
+	// Scoped instances are built once per ServiceScope, they can't be resolved from the ServiceProvider itself
+	Scoped
+)
+
+func (l ServiceLifetime) String() string {
+	switch l {
+	case Singleton:
+		return "singleton"
+	case Transient:
+		return "transient"
+	case Scoped:
+		return "scoped"
+	default:
+		return "unknown"
+	}
+}
+
+// IServiceResolver resolves registered services, it is implemented by *ServiceProvider and *ServiceScope.
+// Factories receive a resolver tracking the current resolution, use it to resolve their own dependencies.
+type IServiceResolver interface {
+	resolve(serviceType reflect.Type, chain []reflect.Type, inSingleton bool) (any, error)
+}
+
+// serviceRegistration is the factory registered for a service type and the singleton instance once built.
+type serviceRegistration struct {
+	serviceType reflect.Type
+	lifetime    ServiceLifetime
+	factory     func(resolver IServiceResolver) (any, error)
+	// dependsOn are the services the factory can resolve
+	dependsOn []reflect.Type
+
+	mutex    sync.Mutex
+	built    bool
+	instance any
+}
+
+// ServiceNotRegisteredError is returned when a service, or one of its dependencies, has no registration.
+type ServiceNotRegisteredError struct {
+	ServiceType reflect.Type
+	// Chain is the resolution path which led to ServiceType, the first one is the service being resolved
+	Chain []reflect.Type
+}
+
+func (e *ServiceNotRegisteredError) Error() string {
+	if len(e.Chain) == 0 {
+		return fmt.Sprintf("service %s is not registered", e.ServiceType)
+	}
+	return fmt.Sprintf("service %s is not registered, required by %s", e.ServiceType, formatChain(e.Chain))
+}
+
+func formatChain(chain []reflect.Type) string {
+	names := make([]string, len(chain))
+	for i, serviceType := range chain {
+		names[i] = serviceType.String()
+	}
+	return strings.Join(names, " -> ")
+}
+
+// Register registers factory to build the services of type T with lifetime. The factory is lazy, it is called on the first Resolve.
+// dependsOn are the services the factory resolves, e.g. reflect.TypeFor[logging.ILoggerFactory](), it can't resolve the others.
+// Registering the same T again replaces the previous registration.
+func Register[T any](sp *ServiceProvider, lifetime ServiceLifetime, factory func(resolver IServiceResolver) (T, error), dependsOn ...reflect.Type) {
+	serviceType := reflect.TypeFor[T]()
+	sp.registrationsMutex.Lock()
+	defer sp.registrationsMutex.Unlock()
+	sp.registrations[serviceType] = &serviceRegistration{
+		serviceType: serviceType,
+		lifetime:    lifetime,
+		factory: func(resolver IServiceResolver) (any, error) {
+			return factory(resolver)
+		},
+		dependsOn: slices.Clone(dependsOn),
+	}
+	sp.checkedServices.Clear()
+}
+
+// RegisterInstance registers an already built singleton of type T.
+func RegisterInstance[T any](sp *ServiceProvider, instance T) {
+	serviceType := reflect.TypeFor[T]()
+	sp.registrationsMutex.Lock()
+	defer sp.registrationsMutex.Unlock()
+	sp.registrations[serviceType] = &serviceRegistration{
+		serviceType: serviceType,
+		lifetime:    Singleton,
+		built:       true,
+		instance:    instance,
+	}
+	sp.checkedServices.Clear()
+}
+
+// Resolve returns the service of type T, building it and its dependencies when needed.
+func Resolve[T any](resolver IServiceResolver) (T, error) {
+	var zero T
+	instance, err := resolver.resolve(reflect.TypeFor[T](), nil, false)
+	if err != nil {
+		return zero, err
+	}
+	if instance == nil {
+		return zero, nil
+	}
+	return instance.(T), nil
+}
+
+// MustResolve is Resolve which panics on error, for services validated at startup.
+func MustResolve[T any](resolver IServiceResolver) T {
+	instance, err := Resolve[T](resolver)
+	if err != nil {
+		panic(err)
+	}
+	return instance
+}
+
+func (sp *ServiceProvider) registration(serviceType reflect.Type) *serviceRegistration {
+	sp.registrationsMutex.RLock()
+	defer sp.registrationsMutex.RUnlock()
+	return sp.registrations[serviceType]
+}
+
+// checkDependencies reports the cycles of the dependencies of serviceType, and the scoped services they make a singleton resolve.
+// Once it passes, the instance locks are taken in dependency order and the concurrent resolutions can't deadlock.
+func (sp *ServiceProvider) checkDependencies(serviceType reflect.Type) error {
+	if checked, ok := sp.checkedServices.Load(serviceType); ok {
+		err, _ := checked.(error)
+		return err
+	}
+	sp.registrationsMutex.RLock()
+	validation := newDependencyValidation(maps.Clone(sp.registrations))
+	sp.registrationsMutex.RUnlock()
+	validation.visit(serviceType, nil)
+	// the missing services are reported by the resolution, with the type of the error
+	err := errors.Join(validation.errs...)
+	sp.checkedServices.Store(serviceType, err)
+	return err
+}
+
+func (sp *ServiceProvider) resolve(serviceType reflect.Type, chain []reflect.Type, inSingleton bool) (any, error) {
+	return resolveService(sp, nil, serviceType, chain, inSingleton)
+}
+
+// resolveService resolves serviceType from the provider, or from scope when it is not nil.
+func resolveService(sp *ServiceProvider, scope *ServiceScope, serviceType reflect.Type, chain []reflect.Type, inSingleton bool) (any, error) {
+	if slices.Contains(chain, serviceType) {
+		return nil, fmt.Errorf("service dependency cycle %s -> %s", formatChain(chain), serviceType)
+	}
+	if len(chain) == 0 {
+		err := sp.checkDependencies(serviceType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	registration := sp.registration(serviceType)
+	if registration == nil {
+		return nil, &ServiceNotRegisteredError{ServiceType: serviceType, Chain: chain}
+	}
+	resolver := &serviceResolution{
+		sp:          sp,
+		scope:       scope,
+		chain:       append(slices.Clip(chain), serviceType),
+		dependsOn:   registration.dependsOn,
+		inSingleton: inSingleton || registration.lifetime == Singleton,
+	}
+	switch registration.lifetime {
+	case Singleton:
+		// the factory runs under the lock, checkDependencies ruled out the cycles which could deadlock
+		registration.mutex.Lock()
+		defer registration.mutex.Unlock()
+		if registration.built {
+			return registration.instance, nil
+		}
+		// singletons outlive every scope, so they must not capture scoped services
+		resolver.scope = nil
+		instance, err := registration.factory(resolver)
+		if err != nil {
+			return nil, err
+		}
+		registration.instance = instance
+		registration.built = true
+		return instance, nil
+	case Scoped:
+		if inSingleton {
+			return nil, fmt.Errorf("scoped service %s can't be resolved by the singleton %s", serviceType, formatChain(chain))
+		}
+		if scope == nil {
+			return nil, fmt.Errorf("scoped service %s can't be resolved outside of a scope", serviceType)
+		}
+		return scope.resolveScoped(registration, resolver)
+	default:
+		return registration.factory(resolver)
+	}
+}
+
+// serviceResolution is the resolver given to factories, it carries the resolution chain to detect cycles.
+type serviceResolution struct {
+	sp    *ServiceProvider
+	scope *ServiceScope
+	chain []reflect.Type
+	// dependsOn are the services declared by the registration of the factory
+	dependsOn   []reflect.Type
+	inSingleton bool
+}
+
+func (r *serviceResolution) resolve(serviceType reflect.Type, _ []reflect.Type, _ bool) (any, error) {
+	if !slices.Contains(r.dependsOn, serviceType) {
+		return nil, fmt.Errorf("service %s resolves %s which is not one of its dependencies", formatChain(r.chain), serviceType)
+	}
+	return resolveService(r.sp, r.scope, serviceType, r.chain, r.inSingleton)
+}
+
+// ServiceScope holds the scoped service instances, Close releases them.
+type ServiceScope struct {
+	sp *ServiceProvider
+
+	mutex     sync.Mutex
+	instances map[reflect.Type]*scopedInstance
+	// closers are the disposables and the scoped instances implementing io.Closer, in registration order
+	closers []io.Closer
+	closed  bool
+}
+
+type scopedInstance struct {
+	mutex    sync.Mutex
+	built    bool
+	instance any
+}
+
+// CreateScope creates a scope for resolving Scoped services, the caller must Close it.
+func (sp *ServiceProvider) CreateScope() *ServiceScope {
+	return &ServiceScope{
+		sp:        sp,
+		instances: make(map[reflect.Type]*scopedInstance),
+	}
+}
+
+func (s *ServiceScope) resolve(serviceType reflect.Type, chain []reflect.Type, inSingleton bool) (any, error) {
+	return resolveService(s.sp, s, serviceType, chain, inSingleton)
+}
+
+func (s *ServiceScope) resolveScoped(registration *serviceRegistration, resolver IServiceResolver) (any, error) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("scoped service %s resolved from a closed scope", registration.serviceType)
+	}
+	entry, ok := s.instances[registration.serviceType]
+	if !ok {
+		entry = &scopedInstance{}
+		s.instances[registration.serviceType] = entry
+	}
+	s.mutex.Unlock()
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.built {
+		return entry.instance, nil
+	}
+	instance, err := registration.factory(resolver)
+	if err != nil {
+		return nil, err
+	}
+	entry.instance = instance
+	entry.built = true
+	if closer, ok := instance.(io.Closer); ok {
+		s.AddDisposable(closer)
+	}
+	return instance, nil
+}
+
+// AddDisposable registers closer to be closed with the scope, scoped instances implementing io.Closer are registered automatically.
+func (s *ServiceScope) AddDisposable(closer io.Closer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closers = append(s.closers, closer)
+}
+
+// OnClose registers fn to be called when the scope is closed.
+func (s *ServiceScope) OnClose(fn func() error) {
+	s.AddDisposable(closerFunc(fn))
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// Close closes the disposables in reverse registration order.
+func (s *ServiceScope) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	closers := s.closers
+	s.closers = nil
+	s.mutex.Unlock()
+	var errs []error
+	for _, closer := range slices.Backward(closers) {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateServices checks the dependencies declared by the registrations without calling the factories, and reports
+// all the dependency cycles, the scoped services required by singletons and the missing services with the services requiring them.
+func (sp *ServiceProvider) ValidateServices() error {
+	sp.registrationsMutex.RLock()
+	validation := newDependencyValidation(maps.Clone(sp.registrations))
+	sp.registrationsMutex.RUnlock()
+	for _, serviceType := range sortedTypes(maps.Keys(validation.registrations)) {
+		validation.visit(serviceType, nil)
+	}
+	errs := validation.errs
+	for _, serviceType := range sortedTypes(maps.Keys(validation.missing)) {
+		errs = append(errs, fmt.Errorf("missing service %s, required by %s", serviceType, strings.Join(slices.Compact(slices.Sorted(slices.Values(validation.missing[serviceType]))), ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+func sortedTypes(types iter.Seq[reflect.Type]) []reflect.Type {
+	return slices.SortedFunc(types, func(a, b reflect.Type) int {
+		return strings.Compare(a.String(), b.String())
+	})
+}
+
+// dependencyValidation walks the dependencies declared by registrations.
+type dependencyValidation struct {
+	registrations map[reflect.Type]*serviceRegistration
+	// visited are the services already checked, with the path to the scoped service they resolve outside of a singleton
+	visited map[reflect.Type][]reflect.Type
+	// missing are the resolution chains requiring each missing service
+	missing map[reflect.Type][]string
+	errs    []error
+}
+
+func newDependencyValidation(registrations map[reflect.Type]*serviceRegistration) *dependencyValidation {
+	return &dependencyValidation{
+		registrations: registrations,
+		visited:       make(map[reflect.Type][]reflect.Type),
+		missing:       make(map[reflect.Type][]string),
+	}
+}
+
+// visit checks serviceType, reached through chain, and its dependencies. It returns the path from serviceType
+// to the first scoped service it resolves without a singleton in between, or nil when there is none.
+func (v *dependencyValidation) visit(serviceType reflect.Type, chain []reflect.Type) []reflect.Type {
+	if slices.Contains(chain, serviceType) {
+		v.errs = append(v.errs, fmt.Errorf("service dependency cycle %s -> %s", formatChain(chain), serviceType))
+		return nil
+	}
+	if scopedPath, ok := v.visited[serviceType]; ok {
+		return scopedPath
+	}
+	registration := v.registrations[serviceType]
+	if registration == nil {
+		v.missing[serviceType] = append(v.missing[serviceType], formatChain(chain))
+		return nil
+	}
+	chain = append(slices.Clip(chain), serviceType)
+	var scopedPath []reflect.Type
+	for _, dependency := range registration.dependsOn {
+		path := v.visit(dependency, chain)
+		switch {
+		case path == nil:
+		case registration.lifetime == Singleton:
+			scoped := path[len(path)-1]
+			v.errs = append(v.errs, fmt.Errorf("scoped service %s can't be resolved by the singleton %s", scoped, formatChain(slices.Concat(chain, path[:len(path)-1]))))
+		case scopedPath == nil:
+			scopedPath = append([]reflect.Type{serviceType}, path...)
+		}
+	}
+	if registration.lifetime == Scoped {
+		scopedPath = []reflect.Type{serviceType}
+	}
+	v.visited[serviceType] = scopedPath
+	return scopedPath
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testRepository struct {
+	closed bool
+}
+
+func (r *testRepository) Close() error {
+	r.closed = true
+	return nil
+}
+
+type testHandler struct {
+	repository *testRepository
+}
+
+type testClock struct{}
+
+type cycleA struct{}
+
+type cycleB struct{}
+
+type missingService struct{}
+
+// countingFactory returns a factory building new instances of T and the number of its calls.
+func countingFactory[T any]() (func(IServiceResolver) (*T, error), *atomic.Int32) {
+	calls := &atomic.Int32{}
+	return func(IServiceResolver) (*T, error) {
+		calls.Add(1)
+		return new(T), nil
+	}, calls
+}
+
+func TestServiceLifetimes(t *testing.T) {
+	sp := NewEmptyServiceProvider()
+	clockFactory, _ := countingFactory[testClock]()
+	Register(sp, Singleton, clockFactory)
+	repositoryFactory, _ := countingFactory[testRepository]()
+	Register(sp, Scoped, repositoryFactory)
+	Register(sp, Transient, func(resolver IServiceResolver) (*testHandler, error) {
+		repository, err := Resolve[*testRepository](resolver)
+		return &testHandler{repository: repository}, err
+	}, reflect.TypeFor[*testRepository]())
+
+	if MustResolve[*testClock](sp) != MustResolve[*testClock](sp) {
+		t.Error("the singleton is built twice")
+	}
+	if _, err := Resolve[*testRepository](sp); err == nil {
+		t.Error("a scoped service is resolved outside of a scope")
+	}
+
+	scope := sp.CreateScope()
+	first, second := MustResolve[*testHandler](scope), MustResolve[*testHandler](scope)
+	if first == second {
+		t.Error("the transient service is reused")
+	}
+	if first.repository != second.repository {
+		t.Error("the scoped service is built twice in the same scope")
+	}
+	if MustResolve[*testClock](scope) != MustResolve[*testClock](sp) {
+		t.Error("the scope has its own singleton")
+	}
+	otherScope := sp.CreateScope()
+	if MustResolve[*testRepository](otherScope) == first.repository {
+		t.Error("the scoped service is shared by two scopes")
+	}
+
+	if err := scope.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !first.repository.closed {
+		t.Error("the scoped service is not closed with its scope")
+	}
+	if _, err := Resolve[*testRepository](scope); err == nil {
+		t.Error("a scoped service is resolved from a closed scope")
+	}
+	_ = otherScope.Close()
+}
+
+func TestSingletonsAreBuiltOnce(t *testing.T) {
+	sp := NewEmptyServiceProvider()
+	factory, calls := countingFactory[testClock]()
+	Register(sp, Singleton, func(resolver IServiceResolver) (*testClock, error) {
+		time.Sleep(10 * time.Millisecond)
+		return factory(resolver)
+	})
+	wait := sync.WaitGroup{}
+	for range 10 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			MustResolve[*testClock](sp)
+		}()
+	}
+	wait.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("the factory is called %d times", calls.Load())
+	}
+}
+
+func TestFactoriesOnlyResolveTheirDependencies(t *testing.T) {
+	sp := NewEmptyServiceProvider()
+	clockFactory, _ := countingFactory[testClock]()
+	Register(sp, Singleton, clockFactory)
+	Register(sp, Transient, func(resolver IServiceResolver) (*testHandler, error) {
+		_, err := Resolve[*testClock](resolver)
+		return &testHandler{}, err
+	})
+	if _, err := Resolve[*testHandler](sp); err == nil || !strings.Contains(err.Error(), "not one of its dependencies") {
+		t.Errorf("an undeclared dependency is resolved: %v", err)
+	}
+}
+
+func registerCycle(sp *ServiceProvider, calls *atomic.Int32) {
+	Register(sp, Singleton, func(resolver IServiceResolver) (*cycleA, error) {
+		calls.Add(1)
+		_, err := Resolve[*cycleB](resolver)
+		return &cycleA{}, err
+	}, reflect.TypeFor[*cycleB]())
+	Register(sp, Singleton, func(resolver IServiceResolver) (*cycleB, error) {
+		calls.Add(1)
+		_, err := Resolve[*cycleA](resolver)
+		return &cycleB{}, err
+	}, reflect.TypeFor[*cycleA]())
+}
+
+func TestDependencyCycles(t *testing.T) {
+	sp := NewEmptyServiceProvider()
+	calls := &atomic.Int32{}
+	registerCycle(sp, calls)
+
+	err := sp.ValidateServices()
+	if err == nil || strings.Count(err.Error(), "service dependency cycle") != 1 {
+		t.Errorf("got %v", err)
+	}
+
+	// resolving both ends from two goroutines used to deadlock on the singleton locks
+	done := make(chan error, 2)
+	go func() {
+		_, err := Resolve[*cycleA](sp)
+		done <- err
+	}()
+	go func() {
+		_, err := Resolve[*cycleB](sp)
+		done <- err
+	}()
+	for range 2 {
+		select {
+		case err := <-done:
+			if err == nil || !strings.Contains(err.Error(), "service dependency cycle") {
+				t.Errorf("got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the resolution of a cycle is stuck")
+		}
+	}
+	if calls.Load() != 0 {
+		t.Errorf("the factories of the cycle are called %d times", calls.Load())
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	sp := NewEmptyServiceProvider()
+	calls := &atomic.Int32{}
+	Register(sp, Singleton, func(resolver IServiceResolver) (*testClock, error) {
+		calls.Add(1)
+		_, err := Resolve[*missingService](resolver)
+		return &testClock{}, err
+	}, reflect.TypeFor[*missingService]())
+	Register(sp, Scoped, func(IServiceResolver) (*testRepository, error) {
+		calls.Add(1)
+		return &testRepository{}, nil
+	}, reflect.TypeFor[*missingService]())
+	Register(sp, Transient, func(IServiceResolver) (*testHandler, error) {
+		calls.Add(1)
+		return &testHandler{}, nil
+	}, reflect.TypeFor[*testRepository](), reflect.TypeFor[*testClock]())
+
+	err := sp.ValidateServices()
+	if err == nil {
+		t.Fatal("the missing service is not reported")
+	}
+	expected := "missing service *services.missingService, required by *services.testClock, *services.testHandler -> *services.testRepository"
+	if err.Error() != expected {
+		t.Errorf("got %q, want %q", err.Error(), expected)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("the factories are called %d times", calls.Load())
+	}
+	var notRegistered *ServiceNotRegisteredError
+	if _, err = Resolve[*testClock](sp); !errors.As(err, &notRegistered) || notRegistered.ServiceType != reflect.TypeFor[*missingService]() {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestSingletonsCantResolveScopedServices(t *testing.T) {
+	sp := NewEmptyServiceProvider()
+	repositoryFactory, _ := countingFactory[testRepository]()
+	Register(sp, Scoped, repositoryFactory)
+	Register(sp, Transient, func(resolver IServiceResolver) (*testHandler, error) {
+		repository, err := Resolve[*testRepository](resolver)
+		return &testHandler{repository: repository}, err
+	}, reflect.TypeFor[*testRepository]())
+	clockFactory, calls := countingFactory[testClock]()
+	Register(sp, Singleton, clockFactory, reflect.TypeFor[*testHandler]())
+
+	expected := "scoped service *services.testRepository can't be resolved by the singleton *services.testClock -> *services.testHandler"
+	if err := sp.ValidateServices(); err == nil || err.Error() != expected {
+		t.Errorf("got %v, want %q", err, expected)
+	}
+	scope := sp.CreateScope()
+	defer scope.Close()
+	if _, err := Resolve[*testClock](scope); err == nil {
+		t.Error("the singleton is built with a scoped service")
+	}
+	if calls.Load() != 0 {
+		t.Errorf("the singleton factory is called %d times", calls.Load())
+	}
+	if _, err := Resolve[*testHandler](scope); err != nil {
+		t.Errorf("the transient service isn't resolved in a scope: %v", err)
+	}
+}
@@ -3,22 +3,38 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"httpServer/logging"
+	"maps"
 	"net/http"
 	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 )
 
 type ServiceProvider struct {
-	// Logger is the logger for the service. // TODO: add scoped logger factory
+	// Logger is the root logger for the service, use CreateLogger for a logger of a category.
 	Logger logging.ILogger
-	// Configuration is the Configuration for the service, some service may provide Configuration renew policy, so the value may change during the same scope.
-	// Copy your own Configuration struct to use it, this is just a pointer to the Configuration in memory.
-	Configuration *Configuration
+	// LoggerFactory creates the category loggers, it can be nil when only a Logger is added.
+	LoggerFactory logging.ILoggerFactory
+	// configuration is the Configuration for the service, see ServiceProvider.Configuration.
+	configuration atomic.Pointer[Configuration]
+	// configurationSubscribers are notified by UpdateConfiguration, the key is used for unsubscribing.
+	configurationSubscribers     map[int]func(previous *Configuration, current *Configuration)
+	configurationSubscribersNext int
+	configurationMutex           sync.Mutex
 
-	// HttpService is the ctx wrapper for the http server. It is used to run the server and handle requests.
-	HttpService *HttpService
+	// services are the hosted services in registration order, see AddService.
+	services []*hostedService
+	// registrations are the services which can be resolved, see Register and Resolve.
+	registrations      map[reflect.Type]*serviceRegistration
+	registrationsMutex sync.RWMutex
+	// checkedServices are the errors of the dependency graphs of the resolved services, cleared by Register
+	checkedServices sync.Map
 
 	// StoppingContext is the context which is used to stop the service. It is used to wait for the service to be stopped.
 	StoppingContext context.Context
@@ -28,62 +44,233 @@ type ServiceProvider struct {
 
 func NewEmptyServiceProvider() *ServiceProvider {
 	return &ServiceProvider{
-		Logger:          nil,
-		Configuration:   nil,
-		HttpService:     nil,
-		StoppingContext: nil,
-		StoppingCancel:  nil,
+		Logger:                   nil,
+		configurationSubscribers: make(map[int]func(previous *Configuration, current *Configuration)),
+		registrations:            make(map[reflect.Type]*serviceRegistration),
+		StoppingContext:          nil,
+		StoppingCancel:           nil,
 	}
 }
 
-func (sp *ServiceProvider) Run(ctx context.Context) {
+// hostedService is an IService registered with AddService and its running state.
+type hostedService struct {
+	name      string
+	service   IService
+	dependsOn []string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// AddService registers a service hosted by Run, name must be unique and is used by dependsOn of other services.
+// A service is initialized and started after all of its dependencies, and stopped before them.
+func (sp *ServiceProvider) AddService(name string, service IService, dependsOn ...string) {
+	sp.services = append(sp.services, &hostedService{
+		name:      name,
+		service:   service,
+		dependsOn: dependsOn,
+	})
+}
+
+// Run initializes and starts the services in dependency order, waits for SIGINT, SIGTERM, ctx or a failing service,
+// then stops the services in reverse order. The returned error joins the errors of the services which failed.
+func (sp *ServiceProvider) Run(ctx context.Context) error {
 	sp.Logger.Information("Starting application")
+	defer sp.flushLogs()
 	sp.StoppingContext, sp.StoppingCancel = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
-	wg := sync.WaitGroup{}
 	defer sp.StoppingCancel()
 
-	go func() {
-		ctx, cancel := context.WithCancel(sp.StoppingContext)
-		defer cancel()
-		wg.Add(1)
-		defer wg.Done()
-		err := sp.HttpService.Init(sp)
+	err := sp.ValidateServices()
+	if err != nil {
+		return err
+	}
+	ordered, err := sp.orderServices()
+	if err != nil {
+		return err
+	}
+	started := make([]*hostedService, 0, len(ordered))
+	for _, hosted := range ordered {
+		sp.Logger.Debug("Starting service %s", hosted.name)
+		err = hosted.service.Init(sp)
 		if err != nil {
-			sp.Logger.Warning("Error initializing http service: %v", err)
-			return
+			hosted.err = fmt.Errorf("initializing service %s: %w", hosted.name, err)
+			sp.Logger.Warning("Error initializing service %s: %v", hosted.name, err)
+			sp.StoppingCancel()
+			break
 		}
-		err = sp.HttpService.Run(ctx)
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			sp.Logger.Warning("Error running http service: %v", err)
-		}
-	}()
+		sp.startService(hosted)
+		started = append(started, hosted)
+	}
 
-	sp.Logger.Information("Application started")
+	if err == nil {
+		sp.Logger.Information("Application started")
+	}
 	<-sp.StoppingContext.Done()
 	sp.Logger.Information("Stopping application")
-	wg.Wait()
+	for _, hosted := range slices.Backward(started) {
+		sp.Logger.Debug("Stopping service %s", hosted.name)
+		hosted.cancel()
+		<-hosted.done
+	}
 	sp.Logger.Information("Application stopped")
+
+	var errs []error
+	for _, hosted := range ordered {
+		if hosted.err != nil {
+			errs = append(errs, hosted.err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushLogs writes the log entries still buffered by asynchronous sinks.
+func (sp *ServiceProvider) flushLogs() {
+	flusher, ok := sp.LoggerFactory.(logging.IFlusher)
+	if !ok {
+		return
+	}
+	err := flusher.Flush()
+	if err != nil {
+		sp.Logger.Warning("Error flushing logs: %v", err)
+	}
 }
 
-func (sp *ServiceProvider) AddHttpServiceFactory(builder func() *HttpService) {
-	sp.HttpService = builder()
+// startService runs hosted in its own goroutine, a service failing stops the whole application.
+func (sp *ServiceProvider) startService(hosted *hostedService) {
+	var ctx context.Context
+	ctx, hosted.cancel = context.WithCancel(context.Background())
+	hosted.done = make(chan struct{})
+	go func() {
+		defer close(hosted.done)
+		err := hosted.service.Run(ctx)
+		if err == nil || errors.Is(err, http.ErrServerClosed) || errors.Is(err, context.Canceled) {
+			sp.Logger.Debug("Service %s stopped", hosted.name)
+			return
+		}
+		hosted.err = fmt.Errorf("running service %s: %w", hosted.name, err)
+		sp.Logger.Error("Error running service %s: %v", hosted.name, err)
+		sp.StoppingCancel()
+	}()
 }
-func (sp *ServiceProvider) AddHttpService(server *HttpService) {
-	sp.HttpService = server
+
+// orderServices sorts the services so every service comes after its dependencies, keeping the registration order otherwise.
+func (sp *ServiceProvider) orderServices() ([]*hostedService, error) {
+	byName := make(map[string]*hostedService, len(sp.services))
+	for _, hosted := range sp.services {
+		if _, ok := byName[hosted.name]; ok {
+			return nil, fmt.Errorf("service %s is registered twice", hosted.name)
+		}
+		byName[hosted.name] = hosted
+	}
+	ordered := make([]*hostedService, 0, len(sp.services))
+	// visiting holds the services on the current dependency path, to report cycles
+	visiting := make(map[string]bool)
+	visited := make(map[string]bool)
+	var visit func(hosted *hostedService, path []string) error
+	visit = func(hosted *hostedService, path []string) error {
+		if visited[hosted.name] {
+			return nil
+		}
+		path = append(path, hosted.name)
+		if visiting[hosted.name] {
+			return fmt.Errorf("service dependency cycle %s", strings.Join(path, " -> "))
+		}
+		visiting[hosted.name] = true
+		for _, dependency := range hosted.dependsOn {
+			dependencyService, ok := byName[dependency]
+			if !ok {
+				return fmt.Errorf("service %s depends on %s which is not registered", hosted.name, dependency)
+			}
+			err := visit(dependencyService, path)
+			if err != nil {
+				return err
+			}
+		}
+		visiting[hosted.name] = false
+		visited[hosted.name] = true
+		ordered = append(ordered, hosted)
+		return nil
+	}
+	for _, hosted := range sp.services {
+		err := visit(hosted, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
 }
 
 func (sp *ServiceProvider) AddConfigurationFactory(builder func() *Configuration) {
-	sp.Configuration = builder()
+	sp.AddConfiguration(builder())
 }
 
+// AddConfiguration sets the Configuration, which is also resolvable as *Configuration and always resolves to the current one.
 func (sp *ServiceProvider) AddConfiguration(config *Configuration) {
-	sp.Configuration = config
+	sp.configuration.Store(config)
+	Register(sp, Transient, func(IServiceResolver) (*Configuration, error) {
+		return sp.Configuration(), nil
+	})
+}
+
+// Configuration is the Configuration for the service, the ConfigurationWatcher may renew it, so the value may change during the same scope.
+// Keep the returned pointer for a consistent view, it is never modified in place, a reload swaps in a new Configuration.
+func (sp *ServiceProvider) Configuration() *Configuration {
+	return sp.configuration.Load()
+}
+
+// UpdateConfiguration validates config, swaps it in and notifies the subscribers.
+// The current Configuration is kept when config is invalid, or when none of its fields changed, see ChangedConfigurationPaths.
+func (sp *ServiceProvider) UpdateConfiguration(config *Configuration) error {
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+	sp.configurationMutex.Lock()
+	defer sp.configurationMutex.Unlock()
+	previous := sp.configuration.Load()
+	if previous != nil && len(ChangedConfigurationPaths(previous, config)) == 0 {
+		return nil
+	}
+	sp.configuration.Store(config)
+	for _, key := range slices.Sorted(maps.Keys(sp.configurationSubscribers)) {
+		sp.configurationSubscribers[key](previous, config)
+	}
+	return nil
 }
 
-func (sp *ServiceProvider) AddLoggerFactory(builder func() logging.ILogger) {
-	sp.Logger = builder()
+// OnConfigurationChanged registers fn to be called after every UpdateConfiguration, in registration order.
+// fn must not call UpdateConfiguration nor OnConfigurationChanged. The returned function unsubscribes fn.
+func (sp *ServiceProvider) OnConfigurationChanged(fn func(previous *Configuration, current *Configuration)) (unsubscribe func()) {
+	sp.configurationMutex.Lock()
+	defer sp.configurationMutex.Unlock()
+	key := sp.configurationSubscribersNext
+	sp.configurationSubscribersNext++
+	sp.configurationSubscribers[key] = fn
+	return func() {
+		sp.configurationMutex.Lock()
+		defer sp.configurationMutex.Unlock()
+		delete(sp.configurationSubscribers, key)
+	}
+}
+
+// AddLoggerFactory sets the LoggerFactory, which is also resolvable as logging.ILoggerFactory, and its root logger as Logger.
+func (sp *ServiceProvider) AddLoggerFactory(factory logging.ILoggerFactory) {
+	sp.LoggerFactory = factory
+	RegisterInstance(sp, factory)
+	sp.AddLogger(factory.CreateLogger(""))
+}
+
+// CreateLogger returns a logger of category, or the root Logger when there is no LoggerFactory.
+func (sp *ServiceProvider) CreateLogger(category string) logging.ILogger {
+	if sp.LoggerFactory == nil {
+		return sp.Logger
+	}
+	return sp.LoggerFactory.CreateLogger(category)
 }
 
+// AddLogger sets the Logger, which is also resolvable as logging.ILogger.
 func (sp *ServiceProvider) AddLogger(logger logging.ILogger) {
 	sp.Logger = logger
+	RegisterInstance(sp, logger)
 }
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"httpServer/logging"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serviceJournal records the calls made by Run to the journaledServices.
+type serviceJournal struct {
+	mutex  sync.Mutex
+	events map[string][]string
+}
+
+func (j *serviceJournal) add(event string, name string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.events[event] = append(j.events[event], name)
+}
+
+func (j *serviceJournal) get(event string) []string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return slices.Clone(j.events[event])
+}
+
+// journaledService records its calls in journal, Init returns initErr and Run returns runErr as soon as it starts.
+type journaledService struct {
+	name    string
+	journal *serviceJournal
+	initErr error
+	runErr  error
+}
+
+func (s *journaledService) Init(*ServiceProvider) error {
+	s.journal.add("init", s.name)
+	return s.initErr
+}
+
+func (s *journaledService) Run(ctx context.Context) error {
+	s.journal.add("start", s.name)
+	if s.runErr != nil {
+		return s.runErr
+	}
+	<-ctx.Done()
+	s.journal.add("stop", s.name)
+	return ctx.Err()
+}
+
+func newHostingServiceProvider() (*ServiceProvider, *serviceJournal) {
+	sp := NewEmptyServiceProvider()
+	factory := logging.NewLoggerFactory(logging.Lowest, nil)
+	_ = factory.SetSinks()
+	sp.AddLoggerFactory(factory)
+	return sp, &serviceJournal{events: make(map[string][]string)}
+}
+
+// runUntilStarted runs sp and cancels it once count services are started, it returns the error of Run.
+func runUntilStarted(t *testing.T, sp *ServiceProvider, journal *serviceJournal, count int) error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- sp.Run(ctx)
+	}()
+	deadline := time.After(5 * time.Second)
+	for len(journal.get("start")) < count {
+		select {
+		case err := <-result:
+			return err
+		case <-deadline:
+			t.Fatalf("only %v are started", journal.get("start"))
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run doesn't return")
+		return nil
+	}
+}
+
+func TestRunFollowsTheDependencies(t *testing.T) {
+	sp, journal := newHostingServiceProvider()
+	sp.AddService("http", &journaledService{name: "http", journal: journal}, "cache", "db")
+	sp.AddService("cache", &journaledService{name: "cache", journal: journal}, "db")
+	sp.AddService("db", &journaledService{name: "db", journal: journal})
+	sp.AddService("metrics", &journaledService{name: "metrics", journal: journal})
+
+	if err := runUntilStarted(t, sp, journal, 4); err != nil {
+		t.Fatal(err)
+	}
+	if inits := journal.get("init"); !slices.Equal(inits, []string{"db", "cache", "http", "metrics"}) {
+		t.Errorf("initialized in the order %v", inits)
+	}
+	if stops := journal.get("stop"); !slices.Equal(stops, []string{"metrics", "http", "cache", "db"}) {
+		t.Errorf("stopped in the order %v", stops)
+	}
+}
+
+func TestRunReportsTheBrokenDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		services map[string][]string
+		error    string
+	}{
+		{"missing", map[string][]string{"http": {"db"}}, "service http depends on db which is not registered"},
+		{"cycle", map[string][]string{"http": {"cache"}, "cache": {"db"}, "db": {"http"}}, "service dependency cycle cache -> db -> http -> cache"},
+		{"self", map[string][]string{"http": {"http"}}, "service dependency cycle http -> http"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sp, journal := newHostingServiceProvider()
+			// a fine service registered first is not started either
+			sp.AddService("metrics", &journaledService{name: "metrics", journal: journal})
+			for _, name := range slices.Sorted(maps.Keys(test.services)) {
+				sp.AddService(name, &journaledService{name: name, journal: journal}, test.services[name]...)
+			}
+			err := sp.Run(context.Background())
+			if err == nil || err.Error() != test.error {
+				t.Errorf("got %v, want %s", err, test.error)
+			}
+			if inits := journal.get("init"); len(inits) != 0 {
+				t.Errorf("%v are initialized", inits)
+			}
+		})
+	}
+
+	sp, journal := newHostingServiceProvider()
+	sp.AddService("http", &journaledService{name: "http", journal: journal})
+	sp.AddService("http", &journaledService{name: "http", journal: journal})
+	if err := sp.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "registered twice") {
+		t.Errorf("the duplicate service is not reported: %v", err)
+	}
+}
+
+func TestFailingServiceStopsTheOthers(t *testing.T) {
+	errListen := errors.New("address already in use")
+	sp, journal := newHostingServiceProvider()
+	sp.AddService("db", &journaledService{name: "db", journal: journal})
+	sp.AddService("http", &journaledService{name: "http", journal: journal, runErr: errListen}, "db")
+	sp.AddService("metrics", &journaledService{name: "metrics", journal: journal})
+
+	// Run returns on its own, the failure stops the application
+	err := runUntilStarted(t, sp, journal, 10)
+	if !errors.Is(err, errListen) || !strings.Contains(err.Error(), "running service http") {
+		t.Errorf("got %v", err)
+	}
+	stops := journal.get("stop")
+	slices.Sort(stops)
+	if !slices.Equal(stops, []string{"db", "metrics"}) {
+		t.Errorf("stopped %v", stops)
+	}
+}
+
+func TestFailingInitStopsTheStartedServices(t *testing.T) {
+	errConfig := errors.New("bad configuration")
+	sp, journal := newHostingServiceProvider()
+	sp.AddService("db", &journaledService{name: "db", journal: journal})
+	sp.AddService("cache", &journaledService{name: "cache", journal: journal, initErr: errConfig}, "db")
+	sp.AddService("http", &journaledService{name: "http", journal: journal}, "cache")
+
+	err := runUntilStarted(t, sp, journal, 10)
+	if !errors.Is(err, errConfig) || !strings.Contains(err.Error(), "initializing service cache") {
+		t.Errorf("got %v", err)
+	}
+	if inits := journal.get("init"); !slices.Equal(inits, []string{"db", "cache"}) {
+		t.Errorf("initialized %v", inits)
+	}
+	if starts, stops := journal.get("start"), journal.get("stop"); !slices.Equal(starts, []string{"db"}) || !slices.Equal(stops, []string{"db"}) {
+		t.Errorf("started %v, stopped %v", starts, stops)
+	}
+}
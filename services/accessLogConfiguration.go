@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"httpServer/logging"
+	"httpServer/validation"
+	"strconv"
+)
+
+// validate checks the AccessLogConfiguration, the returned map is keyed by json path relative to the AccessLogConfiguration.
+func (a *AccessLogConfiguration) validate() map[string][]*validation.ValidateError {
+	errs := make(map[string][]*validation.ValidateError)
+	_, err := NewAccessLogFormatter(a.Format, a.Template)
+	if err != nil {
+		errs["template"] = []*validation.ValidateError{{Reason: err.Error()}}
+	}
+	_, _, err = parseTrustedProxies(a.TrustedProxies)
+	if err != nil {
+		errs["trustedProxies"] = []*validation.ValidateError{{Reason: err.Error()}}
+	}
+	for i, sink := range a.Sinks {
+		for path, results := range sink.validate() {
+			errs["sinks["+strconv.Itoa(i)+"]."+path] = results
+		}
+	}
+	return errs
+}
+
+// CreateAccessLogSinks opens the sinks of the AccessLog, or a sink on stdout when there is none, wrapped by the LogQueue.
+// There is no sink when the AccessLog is disabled.
+func (c *Configuration) CreateAccessLogSinks() ([]logging.ISink, error) {
+	if !c.AccessLog.Enabled {
+		return nil, nil
+	}
+	formatter, err := NewAccessLogFormatter(c.AccessLog.Format, c.AccessLog.Template)
+	if err != nil {
+		return nil, err
+	}
+	configs := c.AccessLog.Sinks
+	if len(configs) == 0 {
+		configs = []LogSinkConfiguration{{Type: "stdout"}}
+	}
+	var sinks []logging.ISink
+	for _, config := range configs {
+		sink, err := config.open(func(logging.ColorTheme) logging.IFormatter { return formatter }, nil)
+		if err != nil {
+			for _, sink := range sinks {
+				err = errors.Join(err, sink.Close())
+			}
+			return nil, err
+		}
+		if c.LogQueue.Size > 0 {
+			sink = logging.NewAsyncSink(sink, c.LogQueue.Size, c.LogQueue.Policy)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
@@ -1,10 +1,16 @@
 package services
 
 import (
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"strings"
 	"time"
 )
 
+// AdminRole is the role of the JWT claims required by the administration endpoints.
+const AdminRole = "admin"
+
 type authorizeService struct {
 	serviceProvider *ServiceProvider
 }
@@ -26,8 +32,8 @@ type IAuthorizeService interface {
 
 func (j authorizeService) generateJwt(claimsBuilder func(claim jwt.Claims)) (string, error) {
 	j.serviceProvider.Logger.Verbose("Generating new JWT")
-	secret := j.serviceProvider.Configuration.JwtSecret
-	config := j.serviceProvider.Configuration
+	secret := j.serviceProvider.Configuration().JwtSecret
+	config := j.serviceProvider.Configuration()
 	claims := jwt.MapClaims{
 		"iss": config.JwtIssuer,
 		"exp": jwt.NewNumericDate(time.Now().Add(time.Minute * 10)),
@@ -35,32 +41,85 @@ func (j authorizeService) generateJwt(claimsBuilder func(claim jwt.Claims)) (str
 	}
 	claimsBuilder(claims)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := token.SignedString([]byte(secret.Reveal()))
 	if err != nil {
 		return "", err
 	}
-	j.serviceProvider.Logger.Trace("Generated new JWT \"%s\" with secret \"%s\"", tokenString, secret)
+	j.serviceProvider.Logger.Trace("Generated new JWT for %v", claims["sub"])
 	return tokenString, nil
 }
 
 func (j authorizeService) validateJwt(token string) (jwt.Claims, error) {
 	logger := j.serviceProvider.Logger
-	config := j.serviceProvider.Configuration
+	config := j.serviceProvider.Configuration()
 	logger.Verbose("Validating JWT")
+	if config.HasDefaultJwtCredentials() {
+		// anyone can sign a token with the secret published in the source code
+		return nil, errors.New("the jwtSecret or the jwtIssuer is the default one")
+	}
 	jwt, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			logger.Warning("Unexpected signing method: %v", token.Header["alg"])
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return []byte(config.JwtSecret), nil
+		return []byte(config.JwtSecret.Reveal()), nil
 	},
 		jwt.WithIssuer(config.JwtIssuer),
 		jwt.WithExpirationRequired(),
 		jwt.WithIssuedAt(),
 	)
 	if err != nil {
-		logger.Verbose("Error parsing jwt \"%s\" with secret \"%s\"\nparse options: %s", err, j.serviceProvider.Configuration.JwtSecret, token)
+		logger.Verbose("Error parsing JWT: %v", err)
 		return nil, err
 	}
 	return jwt.Claims, nil
 }
+
+// AuthenticationMiddleware only lets through the requests with a valid JWT, signed with the JwtSecret and issued by the JwtIssuer,
+// in the "Authorization: Bearer" header. The caller becomes the Principal of the RequestScope, the other requests get 401 Unauthorized.
+func (sp *ServiceProvider) AuthenticationMiddleware(next http.Handler) http.Handler {
+	service := authorizeService{serviceProvider: sp}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the auth scheme is case-insensitive, RFC 7235 section 2.1
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			unauthorized(w)
+			return
+		}
+		claims, err := service.validateJwt(strings.TrimSpace(token))
+		if err != nil {
+			unauthorized(w)
+			return
+		}
+		if scope := RequestScopeFromContext(r.Context()); scope != nil {
+			subject, _ := claims.GetSubject()
+			scope.SetPrincipal(&Principal{Subject: subject, Claims: claims})
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole only lets through the principals having role, see Principal.HasRole. It must follow AuthenticationMiddleware,
+// the authenticated callers without role get 403 Forbidden.
+func (sp *ServiceProvider) RequireRole(role string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope := RequestScopeFromContext(r.Context())
+			if scope == nil || scope.Principal() == nil {
+				unauthorized(w)
+				return
+			}
+			if !scope.Principal().HasRole(role) {
+				scope.Logger.Warning("%s lacks the role %s", scope.Principal().Subject, role)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"httpServer/logging"
+	"os"
+	"sync"
+	"time"
+)
+
+// certificateReloader serves a certificate key pair and reloads it when the files change on disk,
+// so renewed certificates (e.g. by certbot) are picked up without restarting the server.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	reloader := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	_, err := reloader.reload()
+	if err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate.
+func (c *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.certificate, nil
+}
+
+// reload loads the key pair again if any of the files changed since the last load.
+// The current certificate is kept when the new files can't be loaded.
+func (c *certificateReloader) reload() (bool, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	c.mutex.RLock()
+	unchanged := c.certificate != nil && certInfo.ModTime().Equal(c.certModTime) && keyInfo.ModTime().Equal(c.keyModTime)
+	c.mutex.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	c.mutex.Lock()
+	c.certificate = &certificate
+	c.certModTime = certInfo.ModTime()
+	c.keyModTime = keyInfo.ModTime()
+	c.mutex.Unlock()
+	return true, nil
+}
+
+// watch checks the files every interval until ctx is done.
+func (c *certificateReloader) watch(ctx context.Context, interval time.Duration, logger logging.ILogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := c.reload()
+		if err != nil {
+			// a renewal may write the cert and the key one after the other, so this can be transient
+			logger.Warning("Error reloading certificate %s, keeping the current one: %v", c.certFile, err)
+			continue
+		}
+		if reloaded {
+			logger.Information("Certificate %s reloaded", c.certFile)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+)
+
+func servedSerial(t *testing.T, reloader *certificateReloader) int64 {
+	t.Helper()
+	certificate, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+// renew replaces the files of current with a new certificate, like a renewal writing the files in place.
+func renew(t *testing.T, dir string, ca *testCertificate, current *testCertificate) *testCertificate {
+	t.Helper()
+	renewed := newTestCertificate(t, dir, "renewed", ca, x509.ExtKeyUsageServerAuth)
+	for source, target := range map[string]string{renewed.certFile: current.certFile, renewed.keyFile: current.keyFile} {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, target, data)
+	}
+	return renewed
+}
+
+func TestCertificateReloaderReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, 0)
+	server := newTestCertificate(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	reloader, err := newCertificateReloader(server.certFile, server.keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serial := servedSerial(t, reloader); serial != server.certificate.SerialNumber.Int64() {
+		t.Fatalf("serves serial %d", serial)
+	}
+	reloaded, err := reloader.reload()
+	if reloaded || err != nil {
+		t.Errorf("unchanged files reloaded: %v, %v", reloaded, err)
+	}
+
+	renewed := renew(t, dir, ca, server)
+	reloaded, err = reloader.reload()
+	if !reloaded || err != nil {
+		t.Fatalf("renewed files not reloaded: %v, %v", reloaded, err)
+	}
+	if serial := servedSerial(t, reloader); serial != renewed.certificate.SerialNumber.Int64() {
+		t.Errorf("serves serial %d after the renewal", serial)
+	}
+
+	writeTestFile(t, server.keyFile, []byte("not a key"))
+	if _, err = reloader.reload(); err == nil {
+		t.Error("an invalid key is loaded")
+	}
+	if serial := servedSerial(t, reloader); serial != renewed.certificate.SerialNumber.Int64() {
+		t.Errorf("the current certificate is not kept, serves serial %d", serial)
+	}
+}
+
+func TestCertificateReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, 0)
+	server := newTestCertificate(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	reloader, err := newCertificateReloader(server.certFile, server.keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.watch(ctx, 10*time.Millisecond, newTestServiceProvider().Logger)
+
+	renewed := renew(t, dir, ca, server)
+	deadline := time.Now().Add(5 * time.Second)
+	for servedSerial(t, reloader) != renewed.certificate.SerialNumber.Int64() {
+		if time.Now().After(deadline) {
+			t.Fatal("the renewed certificate is not served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHttpServiceServesTheReloadedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, 0)
+	otherCa := newTestCertificate(t, dir, "otherCa", nil, 0)
+	server := newTestCertificate(t, dir, "server", ca, x509.ExtKeyUsageServerAuth)
+	socket := startTlsService(t, &TlsConfiguration{CertFile: server.certFile, KeyFile: server.keyFile, ReloadInterval: 1})
+
+	renew(t, dir, otherCa, server)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		// the renewed certificate is signed by otherCa
+		if _, err := getOverTls(socket, otherCa.pool(), nil); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the renewed certificate is not served")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
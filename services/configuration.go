@@ -2,7 +2,14 @@ package services
 
 import (
 	"encoding/json"
+	"fmt"
 	"httpServer/logging"
+	"httpServer/validation"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 type EnvironmentType string
@@ -46,28 +53,260 @@ func (env *EnvironmentType) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+// ParseEnvironmentType parses the name of an EnvironmentType. Unlike UnmarshalJSON it does not fall back on unknown names.
+func ParseEnvironmentType(s string) (EnvironmentType, error) {
+	switch s {
+	case "development":
+		return DevelopmentEnvironment, nil
+	case "production":
+		return ProductionEnvironment, nil
+	default:
+		return "", fmt.Errorf("unknown environment: %s", s)
+	}
+}
+
 type Configuration struct {
 	// LogLevel is the minimum log level to be logged
 	LogLevel logging.LogLevel `json:"logLevel" env:"CONFIG_LOG_LEVEL" default:"Information"`
+	// LogLevels overrides LogLevel for logger categories and their sub categories, e.g. {"api": "Debug", "services.HttpService": "Warning"}
+	LogLevels map[string]logging.LogLevel `json:"logLevels" env:"CONFIG_LOG_LEVELS" default:""`
+	// LogFormat is the format of the log lines: "console", "json" or "logfmt"
+	LogFormat logging.LogFormat `json:"logFormat" env:"CONFIG_LOG_FORMAT" default:"console"`
+	// LogCaller is how the source of the log lines is reported: "off", "short" (file:line), "function" (package.Function:line) or "full" (path:line)
+	LogCaller logging.CallerFormat `json:"logCaller" env:"CONFIG_LOG_CALLER" default:"short"`
+	// LogColors overrides the colours of the console lines written to terminals by level, e.g. {"Warning": "bold yellow", "Debug": "0;90"}
+	LogColors map[string]string `json:"logColors" env:"CONFIG_LOG_COLORS" default:""`
+	// LogSinks are the outputs of the logs, a console sink on stdout is used when it is empty. It can only be set in the config file
+	LogSinks []LogSinkConfiguration `json:"logSinks" default:""`
+	// LogQueue makes every log sink asynchronous
+	LogQueue LogQueueConfiguration `json:"logQueue"`
+	// LogSampling deduplicates and rate-limits the entries of every log sink
+	LogSampling LogSamplingConfiguration `json:"logSampling"`
+	// LogRedaction masks the credentials of the log entries in addition to the logging.DefaultRedactionKeys and logging.DefaultRedactionPatterns
+	LogRedaction LogRedactionConfiguration `json:"logRedaction"`
+	// AccessLog writes a line per HTTP request to its own sinks
+	AccessLog AccessLogConfiguration `json:"accessLog"`
 	// Environment determines the deploy environment
-	Environment EnvironmentType `json:"environment" env:"CONFIG_ENVIRONMENT" default:"production"`
+	Environment EnvironmentType `json:"environment" env:"CONFIG_ENVIRONMENT" default:"development"`
 	// Port is the port the server will listen on
 	Port int `json:"port" env:"CONFIG_PORT" default:"8080"`
 	// Host is the host the server will listen on
 	Host string `json:"host" env:"CONFIG_HOST" default:"localhost"`
+	// Listen is the list of addresses the server will listen on, e.g. "0.0.0.0:8080", "[::1]:8443" or "unix:/run/httpServer.sock".
+	// Host and Port are used when it is empty
+	Listen []string `json:"listen" env:"CONFIG_LISTEN" default:""`
 	// JwtSecret is the secret used to sign the JWT
-	JwtSecret string `json:"jwtSecret" env:"CONFIG_JWT_SECRET" default:"YOUR_JWT_SECRET_WHICH_SHOULD_BE_LONGER_THAN_32_CHARACTERS_AND_STRONG_ENOUGH"`
+	JwtSecret logging.Secret `json:"jwtSecret" env:"CONFIG_JWT_SECRET" default:"YOUR_JWT_SECRET_WHICH_SHOULD_BE_LONGER_THAN_32_CHARACTERS_AND_STRONG_ENOUGH"`
 	// JwtIssuer is the issuer of the JWT
 	JwtIssuer string `json:"jwtIssuer" env:"CONFIG_JWT_ISSUER" default:"YOUR_JWT_ISSUER"`
+	// ReloadInterval is the interval in seconds between two checks of the config file for changes, 0 to reload on SIGHUP only
+	ReloadInterval int `json:"reloadInterval" env:"CONFIG_RELOAD_INTERVAL" default:"5"`
+	// Tls enables HTTPS on every listen address when a certificate is configured
+	Tls TlsConfiguration `json:"tls"`
 }
 
-func NewDefaultConfig() *Configuration {
-	return &Configuration{
-		LogLevel:    logging.Information,
-		Port:        8080,
-		Host:        "localhost",
-		Environment: DevelopmentEnvironment,
-		JwtSecret:   "YOUR_JWT_SECRET_WHICH_SHOULD_BE_LONGER_THAN_32_CHARACTERS_AND_STRONG_ENOUGH",
-		JwtIssuer:   "YOUR_JWT_ISSUER",
+type TlsConfiguration struct {
+	// CertFile is the PEM encoded certificate chain, TLS is disabled when it is empty
+	CertFile string `json:"certFile" env:"CONFIG_TLS_CERT_FILE" default:""`
+	// KeyFile is the PEM encoded private key of CertFile
+	KeyFile string `json:"keyFile" env:"CONFIG_TLS_KEY_FILE" default:""`
+	// MinVersion is the minimum accepted TLS version, one of "1.0", "1.1", "1.2" or "1.3"
+	MinVersion string `json:"minVersion" env:"CONFIG_TLS_MIN_VERSION" default:"1.2"`
+	// CipherSuites is the list of IANA cipher suite names accepted for TLS 1.2 and lower, empty for the Go defaults
+	CipherSuites []string `json:"cipherSuites" env:"CONFIG_TLS_CIPHER_SUITES" default:""`
+	// ClientCaFile is the PEM encoded CA bundle used to verify client certificates, mutual TLS is disabled when it is empty
+	ClientCaFile string `json:"clientCaFile" env:"CONFIG_TLS_CLIENT_CA_FILE" default:""`
+	// RedirectListen is the list of plain HTTP addresses which redirect every request to HTTPS, empty to disable
+	RedirectListen []string `json:"redirectListen" env:"CONFIG_TLS_REDIRECT_LISTEN" default:""`
+	// ReloadInterval is the interval in seconds between two checks of the certificate files for changes, 0 to disable
+	ReloadInterval int `json:"reloadInterval" env:"CONFIG_TLS_RELOAD_INTERVAL" default:"10"`
+}
+
+type LogQueueConfiguration struct {
+	// Size is the number of entries buffered by each log sink, 0 to write them on the logging goroutine
+	Size int `json:"size" env:"CONFIG_LOG_QUEUE_SIZE" default:"1024"`
+	// Policy is what happens to an entry when the queue is full: "block" waits for room, "drop" discards it
+	Policy logging.OverflowPolicy `json:"policy" env:"CONFIG_LOG_QUEUE_POLICY" default:"block"`
+}
+
+type LogSamplingConfiguration struct {
+	// Window is the period in seconds over which the entries are deduplicated and counted by the limits, 0 to disable the sampling
+	Window int `json:"window" env:"CONFIG_LOG_SAMPLING_WINDOW" default:"0"`
+	// Deduplicate writes an entry once per window, the repetitions are summarized at the end of the window
+	Deduplicate bool `json:"deduplicate" env:"CONFIG_LOG_SAMPLING_DEDUPLICATE" default:"true"`
+	// LevelLimits is the maximum number of entries written per window by level name, e.g. {"Debug": 100}
+	LevelLimits map[string]int `json:"levelLimits" env:"CONFIG_LOG_SAMPLING_LEVEL_LIMITS" default:""`
+	// CategoryLimits is the maximum number of entries written per window by category and its sub categories, e.g. {"api": 50}
+	CategoryLimits map[string]int `json:"categoryLimits" env:"CONFIG_LOG_SAMPLING_CATEGORY_LIMITS" default:""`
+}
+
+type LogRedactionConfiguration struct {
+	// Keys are the field keys whose values are masked, case-insensitive path.Match patterns such as "*session*"
+	Keys []string `json:"keys" env:"CONFIG_LOG_REDACTION_KEYS" default:""`
+	// Patterns are the regular expressions of the text masked in the messages and values, the first group is kept when there is one
+	Patterns []string `json:"patterns" env:"CONFIG_LOG_REDACTION_PATTERNS" default:""`
+}
+
+// CreateRedactor returns the logging.Redactor of the configuration.
+func (r *LogRedactionConfiguration) CreateRedactor() (*logging.Redactor, error) {
+	return logging.NewRedactor(r.Keys, r.Patterns)
+}
+
+type AccessLogConfiguration struct {
+	// Enabled writes a line per request
+	Enabled bool `json:"enabled" env:"CONFIG_ACCESS_LOG_ENABLED" default:"true"`
+	// Format is "combined" (the Apache combined log format), "json" or "template"
+	Format AccessLogFormat `json:"format" env:"CONFIG_ACCESS_LOG_FORMAT" default:"combined"`
+	// Template is the line of the "template" format, the fields are written by name such as "{remoteIp} {method} {uri} {status} {duration}ms"
+	Template string `json:"template" env:"CONFIG_ACCESS_LOG_TEMPLATE" default:""`
+	// TrustedProxies are the IP addresses or CIDR ranges, or "unix" for the Unix sockets, of the proxies whose
+	// X-Forwarded-For and X-Real-Ip headers are used as the remote IP
+	TrustedProxies []string `json:"trustedProxies" env:"CONFIG_ACCESS_LOG_TRUSTED_PROXIES" default:""`
+	// Sinks are the outputs of the access log, their level must let Information through and their format is ignored.
+	// A sink on stdout is used when it is empty. It can only be set in the config file
+	Sinks []LogSinkConfiguration `json:"sinks" default:""`
+}
+
+type LogSinkConfiguration struct {
+	// Type is one of "stdout", "stderr", "file" or "syslog"
+	Type string `json:"type"`
+	// Level is the minimum level written to the sink, LogLevel and LogLevels filter the entries first
+	Level logging.LogLevel `json:"level"`
+	// Format is the format of the lines written to the sink, LogFormat when empty
+	Format logging.LogFormat `json:"format,omitempty"`
+	// Path is the file written by the "file" sink
+	Path string `json:"path,omitempty"`
+	// MaxSize is the size in megabytes of the file before it is rotated, 0 to disable
+	MaxSize int `json:"maxSize,omitempty"`
+	// RotateInterval is the interval in seconds between two rotations of the file, 0 to disable
+	RotateInterval int `json:"rotateInterval,omitempty"`
+	// MaxBackups is the number of rotated files kept, 0 to keep all of them
+	MaxBackups int `json:"maxBackups,omitempty"`
+	// MaxAge is the number of days the rotated files are kept, 0 to keep all of them
+	MaxAge int `json:"maxAge,omitempty"`
+	// Compress gzips the rotated files
+	Compress bool `json:"compress,omitempty"`
+	// Address is the Unix socket of the "syslog" sink, empty for the local syslog daemon
+	Address string `json:"address,omitempty"`
+	// Tag is prepended to the syslog messages, the program name when it is empty
+	Tag string `json:"tag,omitempty"`
+}
+
+// Enabled reports whether HTTPS is configured.
+func (t *TlsConfiguration) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// ConfigurationValidateError reports every invalid field of a Configuration, keyed by json path.
+type ConfigurationValidateError struct {
+	Errors map[string][]*validation.ValidateError
+}
+
+func (e *ConfigurationValidateError) Error() string {
+	builder := strings.Builder{}
+	builder.WriteString("invalid configuration")
+	for _, path := range slices.Sorted(maps.Keys(e.Errors)) {
+		for _, err := range e.Errors[path] {
+			builder.WriteString("; " + path + ": " + err.Reason)
+		}
 	}
+	return builder.String()
+}
+
+// Validate checks the constraints which the field types can't express.
+func (c *Configuration) Validate() error {
+	errs := make(map[string][]*validation.ValidateError)
+	ok, results := validation.Validate(int64(c.Port), validation.DefaultValidateOptions,
+		validation.Integer.Between(1, 65535),
+	)
+	if !ok {
+		errs["port"] = results
+	}
+	for i, address := range c.Listen {
+		_, _, err := ParseListenAddress(address)
+		if err != nil {
+			errs["listen["+strconv.Itoa(i)+"]"] = []*validation.ValidateError{{Reason: err.Error()}}
+		}
+	}
+	ok, results = validation.Validate(int64(c.ReloadInterval), validation.DefaultValidateOptions,
+		validation.Integer.NotLessThan(0),
+	)
+	if !ok {
+		errs["reloadInterval"] = results
+	}
+	for path, results := range c.Tls.validate() {
+		errs["tls."+path] = results
+	}
+	_, err := logging.NewColorTheme(c.LogColors)
+	if err != nil {
+		errs["logColors"] = []*validation.ValidateError{{Reason: err.Error()}}
+	}
+	ok, results = validation.Validate(int64(c.LogQueue.Size), validation.DefaultValidateOptions,
+		validation.Integer.NotLessThan(0),
+	)
+	if !ok {
+		errs["logQueue.size"] = results
+	}
+	_, err = c.LogRedaction.CreateRedactor()
+	if err != nil {
+		errs["logRedaction"] = []*validation.ValidateError{{Reason: err.Error()}}
+	}
+	for path, results := range c.LogSampling.validate() {
+		errs["logSampling."+path] = results
+	}
+	for i, sink := range c.LogSinks {
+		for path, results := range sink.validate() {
+			errs["logSinks["+strconv.Itoa(i)+"]."+path] = results
+		}
+	}
+	for path, results := range c.AccessLog.validate() {
+		errs["accessLog."+path] = results
+	}
+	ok, results = validation.Validate(c.JwtSecret.Reveal(), validation.DefaultValidateOptions,
+		validation.String.NotShorterThan(32),
+	)
+	if !ok {
+		errs["jwtSecret"] = results
+	}
+	ok, results = validation.Validate(c.JwtIssuer, validation.DefaultValidateOptions,
+		validation.String.NotEmptyOrWhiteSpace(),
+	)
+	if !ok {
+		errs["jwtIssuer"] = results
+	}
+	if len(errs) > 0 {
+		return &ConfigurationValidateError{Errors: errs}
+	}
+	return nil
+}
+
+// HasDefaultJwtCredentials reports whether JwtSecret or JwtIssuer is still the value of its `default` tag.
+// The tokens are then forgeable, so they must not be accepted.
+func (c *Configuration) HasDefaultJwtCredentials() bool {
+	defaults := NewDefaultConfig()
+	return c.JwtSecret.Reveal() == defaults.JwtSecret.Reveal() || c.JwtIssuer == defaults.JwtIssuer
+}
+
+// ListenAddresses returns Listen, or Host:Port when Listen is empty.
+func (c *Configuration) ListenAddresses() []string {
+	if len(c.Listen) > 0 {
+		return c.Listen
+	}
+	return []string{net.JoinHostPort(c.Host, strconv.Itoa(c.Port))}
+}
+
+// MarshalFile returns the indented JSON of a config file. The JwtSecret is revealed,
+// encoding/json alone writes it masked like the logged configurations.
+func (c *Configuration) MarshalFile() ([]byte, error) {
+	// the JwtSecret of the outer struct hides the one of the embedded configuration
+	type configurationFile Configuration
+	return json.MarshalIndent(struct {
+		*configurationFile
+		JwtSecret string `json:"jwtSecret"`
+	}{(*configurationFile)(c), c.JwtSecret.Reveal()}, "", "  ")
+}
+
+// NewDefaultConfig returns a Configuration filled with the values of the `default` struct tags.
+func NewDefaultConfig() *Configuration {
+	return NewConfigurationLoader().Configuration()
 }
@@ -0,0 +1,576 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"httpServer/logging"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ConfigurationSource tells where the value of a Configuration field came from.
+type ConfigurationSource string
+
+const (
+	SourceDefault     ConfigurationSource = "default"
+	SourceFile        ConfigurationSource = "file"
+	SourceEnvironment ConfigurationSource = "environment"
+	SourceCommandLine ConfigurationSource = "command line"
+)
+
+// ConfigurationSources maps the json path of a Configuration field (e.g. "logLevel") to the source of its current value.
+type ConfigurationSources map[string]ConfigurationSource
+
+// ConfigurationLoader builds a Configuration layer by layer, the later layer overrides the former one:
+// `default` struct tags, then the json file, then the `env` struct tags, then the command line flags.
+type ConfigurationLoader struct {
+	config  *Configuration
+	sources ConfigurationSources
+}
+
+// NewConfigurationLoader returns a loader whose Configuration is already filled with the default values.
+func NewConfigurationLoader() *ConfigurationLoader {
+	loader := &ConfigurationLoader{
+		config:  &Configuration{},
+		sources: ConfigurationSources{},
+	}
+	err := applyDefaults(loader.config, loader.sources)
+	if err != nil {
+		// every Configuration has the same default tags, NewDefaultConfig would fail for every caller
+		panic(err)
+	}
+	return loader
+}
+
+// Configuration returns the Configuration built so far.
+func (l *ConfigurationLoader) Configuration() *Configuration {
+	return l.config
+}
+
+// Sources returns the source of every Configuration field.
+func (l *ConfigurationLoader) Sources() ConfigurationSources {
+	return l.sources
+}
+
+// ApplyJson overlays the fields present in the json document.
+func (l *ConfigurationLoader) ApplyJson(data []byte) error {
+	err := json.Unmarshal(data, l.config)
+	if err != nil {
+		return err
+	}
+	var document map[string]any
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return err
+	}
+	return walkConfigurationFields(reflect.ValueOf(l.config).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		if jsonPathExists(document, path) {
+			l.sources[path] = SourceFile
+		}
+		return nil
+	})
+}
+
+// ApplyJsonFile overlays the fields present in the json file at path.
+// A missing file is reported as is, so callers can check it with errors.Is(err, fs.ErrNotExist),
+// a malformed file is reported as a *ConfigurationFileError.
+func (l *ConfigurationLoader) ApplyJsonFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	err = l.ApplyJson(data)
+	if err != nil {
+		return newConfigurationFileError(path, data, err)
+	}
+	return nil
+}
+
+// ConfigurationFileError is a json error located in the configuration file.
+type ConfigurationFileError struct {
+	Path string
+	// Line and Column are 1-based, they are 0 when the json decoder doesn't report an offset
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ConfigurationFileError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+}
+
+func (e *ConfigurationFileError) Unwrap() error {
+	return e.Err
+}
+
+func newConfigurationFileError(path string, data []byte, err error) *ConfigurationFileError {
+	var offset int64 = -1
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxError):
+		offset = syntaxError.Offset
+	case errors.As(err, &typeError):
+		offset = typeError.Offset
+	default:
+		// the UnmarshalJSON methods of the field types, e.g. logging.LogLevel, don't know where their value is
+		if start, ok := locateUnmarshalerError(data, reflect.TypeFor[Configuration]()); ok {
+			offset = start + 1
+		}
+	}
+	fileError := &ConfigurationFileError{Path: path, Err: err}
+	if offset < 0 || offset > int64(len(data)) {
+		return fileError
+	}
+	// the offset points right after the offending byte
+	consumed := data[:offset]
+	fileError.Line = bytes.Count(consumed, []byte{'\n'}) + 1
+	fileError.Column = len(consumed) - bytes.LastIndexByte(consumed, '\n') - 1
+	if fileError.Column == 0 {
+		fileError.Column = 1
+	}
+	return fileError
+}
+
+// locateUnmarshalerError returns the offset of the first value of data rejected by the json.Unmarshaler of its field in t.
+func locateUnmarshalerError(data []byte, t reflect.Type) (int64, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	start, found, err := findUnmarshalerError(decoder, data, t)
+	return start, found && err == nil
+}
+
+// findUnmarshalerError reads the next value of decoder as a t, it returns the offset of the value when t, or one of its
+// fields, elements or map values, is a json.Unmarshaler rejecting it. The other values are only read.
+func findUnmarshalerError(decoder *json.Decoder, data []byte, t reflect.Type) (int64, bool, error) {
+	// InputOffset is the end of the previous token, the value starts after the separators
+	start := decoder.InputOffset()
+	for start < int64(len(data)) && strings.ContainsRune(" \t\r\n:,", rune(data[start])) {
+		start++
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err != nil {
+			return 0, false, err
+		}
+		unmarshaler := reflect.New(t).Interface().(json.Unmarshaler)
+		return start, unmarshaler.UnmarshalJSON(raw) != nil, nil
+	}
+	var expected json.Delim
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		expected = '{'
+	case reflect.Slice:
+		expected = '['
+	}
+	if expected == 0 || start >= int64(len(data)) || data[start] != byte(expected) {
+		var raw json.RawMessage
+		return start, false, decoder.Decode(&raw)
+	}
+	_, err := decoder.Token()
+	if err != nil {
+		return 0, false, err
+	}
+	for decoder.More() {
+		var elementType reflect.Type
+		if t.Kind() != reflect.Struct {
+			elementType = t.Elem()
+		}
+		if expected == '{' {
+			key, err := decoder.Token()
+			if err != nil {
+				return 0, false, err
+			}
+			if t.Kind() == reflect.Struct {
+				elementType = reflect.TypeFor[json.RawMessage]()
+				if field, ok := jsonField(t, key.(string)); ok {
+					elementType = field.Type
+				}
+			}
+		}
+		offset, found, err := findUnmarshalerError(decoder, data, elementType)
+		if found || err != nil {
+			return offset, found, err
+		}
+	}
+	_, err = decoder.Token()
+	return 0, false, err
+}
+
+// jsonField returns the field of t decoded from the json key, matched like encoding/json does:
+// the exact name first, then case-insensitively.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var folded reflect.StructField
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == key {
+			return field, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			folded, found = field, true
+		}
+	}
+	return folded, found
+}
+
+// ApplyEnvironment overlays the fields whose `env` variable is set, lookup is usually os.LookupEnv.
+// All the malformed variables are reported in the returned error.
+func (l *ConfigurationLoader) ApplyEnvironment(lookup func(key string) (string, bool)) error {
+	var errs []error
+	err := walkConfigurationFields(reflect.ValueOf(l.config).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		key, ok := field.Tag.Lookup("env")
+		if !ok {
+			return nil
+		}
+		raw, ok := lookup(key)
+		if !ok {
+			return nil
+		}
+		err := setFieldFromString(value, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			return nil
+		}
+		l.sources[path] = SourceEnvironment
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyFlags overlays the fields given on the command line, flags must have been parsed already.
+func (l *ConfigurationLoader) ApplyFlags(flags *ConfigurationFlags) error {
+	var errs []error
+	err := walkConfigurationFields(reflect.ValueOf(l.config).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		raw, ok := flags.values[path]
+		if !ok {
+			return nil
+		}
+		err := setFieldFromString(value, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", flagName(path), err))
+			return nil
+		}
+		l.sources[path] = SourceCommandLine
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// ConfigurationFlags collects the Configuration fields overridden on the command line.
+type ConfigurationFlags struct {
+	values map[string]string
+}
+
+// NewConfigurationFlags defines a flag on set for every Configuration field, named after its json path:
+// logLevel is --log-level and tls.certFile is --tls-cert-file. List fields accept the flag several times.
+func NewConfigurationFlags(set *flag.FlagSet) *ConfigurationFlags {
+	flags := &ConfigurationFlags{values: map[string]string{}}
+	_ = walkConfigurationFields(reflect.ValueOf(&Configuration{}).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		// lists of objects can only be set in the config file
+		if !parsableFromString(value.Type()) {
+			return nil
+		}
+		usage := "overrides " + path
+		if env, ok := field.Tag.Lookup("env"); ok {
+			usage += ", environment variable " + env
+		}
+		if def, ok := field.Tag.Lookup("default"); ok && def != "" {
+			usage += ", default " + def
+		}
+		collect := func(raw string) error {
+			if previous, ok := flags.values[path]; ok && value.Kind() == reflect.Slice {
+				raw = previous + "," + raw
+			}
+			flags.values[path] = raw
+			return nil
+		}
+		if value.Kind() == reflect.Bool {
+			set.BoolFunc(flagName(path), usage, collect)
+		} else {
+			set.Func(flagName(path), usage, collect)
+		}
+		return nil
+	})
+	return flags
+}
+
+// flagName converts a json path to a kebab-case flag name, e.g. "tls.certFile" to "tls-cert-file".
+func flagName(path string) string {
+	builder := strings.Builder{}
+	for i, r := range path {
+		switch {
+		case r == '.':
+			builder.WriteRune('-')
+		case unicode.IsUpper(r):
+			if i > 0 && path[i-1] != '.' {
+				builder.WriteRune('-')
+			}
+			builder.WriteRune(unicode.ToLower(r))
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// ChangedConfigurationPaths returns the json paths of the fields whose value differs between previous and current.
+func ChangedConfigurationPaths(previous *Configuration, current *Configuration) []string {
+	values := make(map[string]any)
+	_ = walkConfigurationFields(reflect.ValueOf(previous).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		values[path] = value.Interface()
+		return nil
+	})
+	var changed []string
+	_ = walkConfigurationFields(reflect.ValueOf(current).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		if !reflect.DeepEqual(values[path], value.Interface()) {
+			changed = append(changed, path)
+		}
+		return nil
+	})
+	return changed
+}
+
+// applyDefaults sets every field which has a non-empty `default` tag, sources can be nil.
+func applyDefaults(config *Configuration, sources ConfigurationSources) error {
+	return walkConfigurationFields(reflect.ValueOf(config).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		raw, ok := field.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+		// an empty default still makes lists and maps empty instead of null
+		if raw != "" || value.Kind() == reflect.Slice || value.Kind() == reflect.Map {
+			err := setFieldFromString(value, raw)
+			if err != nil {
+				return fmt.Errorf("default value of %s: %w", path, err)
+			}
+		}
+		if sources != nil {
+			sources[path] = SourceDefault
+		}
+		return nil
+	})
+}
+
+// walkConfigurationFields calls fn for every leaf field of the struct value.
+// Nested structs are walked recursively, their fields are reported as "parent.child" json paths.
+func walkConfigurationFields(value reflect.Value, prefix string, fn func(path string, field reflect.StructField, value reflect.Value) error) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		if field.Type.Kind() == reflect.Struct {
+			err := walkConfigurationFields(value.Field(i), path, fn)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := fn(path, field, value.Field(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonPathExists reports whether the dotted path exists in the decoded json document.
+func jsonPathExists(document map[string]any, path string) bool {
+	current := document
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		value, ok := jsonObjectValue(current, segment)
+		if !ok {
+			return false
+		}
+		if i == len(segments)-1 {
+			return true
+		}
+		current, ok = value.(map[string]any)
+		if !ok {
+			return false
+		}
+	}
+	return false
+}
+
+// jsonObjectValue returns the value of key in object, matched like encoding/json matches the keys to the fields:
+// the exact key first, then case-insensitively.
+func jsonObjectValue(object map[string]any, key string) (any, bool) {
+	if value, ok := object[key]; ok {
+		return value, true
+	}
+	for name, value := range object {
+		if strings.EqualFold(name, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// parsableFromString reports whether setFieldFromString supports the type.
+func parsableFromString(valueType reflect.Type) bool {
+	switch valueType.Kind() {
+	case reflect.Slice:
+		return parsableFromString(valueType.Elem())
+	case reflect.Map:
+		return parsableFromString(valueType.Key()) && parsableFromString(valueType.Elem())
+	case reflect.Struct, reflect.Pointer, reflect.Interface, reflect.Array, reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128:
+		return false
+	default:
+		return true
+	}
+}
+
+// setFieldFromString parses raw into the type of value, slices are parsed from comma separated lists
+// and maps from comma separated key=value lists.
+func setFieldFromString(value reflect.Value, raw string) error {
+	switch value.Interface().(type) {
+	case logging.LogLevel:
+		level, err := logging.ParseLogLevel(raw)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(level))
+		return nil
+	case logging.LogFormat:
+		format, err := logging.ParseLogFormat(raw)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(format))
+		return nil
+	case logging.OverflowPolicy:
+		policy, err := logging.ParseOverflowPolicy(raw)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(policy))
+		return nil
+	case logging.CallerFormat:
+		format, err := logging.ParseCallerFormat(raw)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(format))
+		return nil
+	case AccessLogFormat:
+		format, err := ParseAccessLogFormat(raw)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(format))
+		return nil
+	case EnvironmentType:
+		env, err := ParseEnvironmentType(raw)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(env))
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(raw), 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(strings.TrimSpace(raw), 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(value.Type(), 0, 0)
+		if strings.TrimSpace(raw) != "" {
+			for _, item := range strings.Split(raw, ",") {
+				element := reflect.New(value.Type().Elem()).Elem()
+				err := setFieldFromString(element, strings.TrimSpace(item))
+				if err != nil {
+					return err
+				}
+				slice = reflect.Append(slice, element)
+			}
+		}
+		value.Set(slice)
+	case reflect.Map:
+		entries := reflect.MakeMap(value.Type())
+		if strings.TrimSpace(raw) != "" {
+			for _, item := range strings.Split(raw, ",") {
+				rawKey, rawValue, ok := strings.Cut(item, "=")
+				if !ok {
+					return fmt.Errorf("%q is not a key=value pair", item)
+				}
+				key := reflect.New(value.Type().Key()).Elem()
+				err := setFieldFromString(key, strings.TrimSpace(rawKey))
+				if err != nil {
+					return err
+				}
+				element := reflect.New(value.Type().Elem()).Elem()
+				err = setFieldFromString(element, strings.TrimSpace(rawValue))
+				if err != nil {
+					return err
+				}
+				entries.SetMapIndex(key, element)
+			}
+		}
+		value.Set(entries)
+	default:
+		return fmt.Errorf("unsupported configuration type %s", value.Type())
+	}
+	return nil
+}
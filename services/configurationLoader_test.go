@@ -0,0 +1,186 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"httpServer/logging"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// loadTestFile applies data written to a config file of the test, the error is the one of ApplyJsonFile.
+func loadTestFile(t *testing.T, data string) (*ConfigurationLoader, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loader := NewConfigurationLoader()
+	return loader, loader.ApplyJsonFile(path)
+}
+
+func TestConfigurationFileErrorsArePositioned(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		line   int
+		column int
+	}{
+		{"syntax", "{\n  \"port\": 80,\n  \"host\": }", 3, 11},
+		{"type", "{\n  \"port\": \"80\"\n}", 2, 14},
+		{"log level", "{\n  \"port\": 80,\n  \"logLevel\": \"Nope\"\n}", 3, 15},
+		{"key case", "{\"LOGFORMAT\": \"xml\"}", 1, 15},
+		{"nested", "{\"logQueue\": {\"size\": 1, \"policy\": \"wait\"}}", 1, 36},
+		{"map value", "{\"logLevels\": {\"api\": \"Debug\",\n \"db\": \"Nope\"}}", 2, 8},
+		{"slice element", "{\"logSinks\": [{\"type\": \"stdout\"}, {\"type\": \"stderr\", \"level\": \"Nope\"}]}", 1, 63},
+		{"after unknown keys", "{\"unknown\": {\"logCaller\": \"x\"}, \"logCaller\": \"none\"}", 1, 46},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadTestFile(t, test.data)
+			var fileError *ConfigurationFileError
+			if !errors.As(err, &fileError) {
+				t.Fatalf("got %v", err)
+			}
+			if fileError.Line != test.line || fileError.Column != test.column {
+				t.Errorf("got %v, want %d:%d", err, test.line, test.column)
+			}
+		})
+	}
+}
+
+func TestConfigurationLoaderLayers(t *testing.T) {
+	loader, err := loadTestFile(t, `{"port": 9000, "host": "0.0.0.0", "logLevel": "Warning", "tls": {"minVersion": "1.3"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	environment := map[string]string{"CONFIG_PORT": "9100", "CONFIG_LOG_LEVEL": "Debug", "CONFIG_LOG_LEVELS": "api=Trace"}
+	err = loader.ApplyEnvironment(func(key string) (string, bool) {
+		value, ok := environment[key]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := NewConfigurationFlags(set)
+	err = set.Parse([]string{"--port", "9200", "--listen", "127.0.0.1:1", "--listen", "[::1]:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = loader.ApplyFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	config := loader.Configuration()
+	if config.Port != 9200 || config.Host != "0.0.0.0" || config.LogLevel != logging.Debug || config.Tls.MinVersion != "1.3" {
+		t.Errorf("got port %d, host %s, log level %s, TLS %s", config.Port, config.Host, config.LogLevel, config.Tls.MinVersion)
+	}
+	if config.Environment != DevelopmentEnvironment || config.ReloadInterval != 5 {
+		t.Errorf("the defaults are not kept: %s, %d", config.Environment, config.ReloadInterval)
+	}
+	if config.LogLevels["api"] != logging.Trace || !slices.Equal(config.Listen, []string{"127.0.0.1:1", "[::1]:2"}) {
+		t.Errorf("got log levels %v, listen %v", config.LogLevels, config.Listen)
+	}
+	expected := map[string]ConfigurationSource{
+		"environment":    SourceDefault,
+		"host":           SourceFile,
+		"tls.minVersion": SourceFile,
+		"logLevel":       SourceEnvironment,
+		"logLevels":      SourceEnvironment,
+		"port":           SourceCommandLine,
+		"listen":         SourceCommandLine,
+	}
+	for path, source := range expected {
+		if loader.Sources()[path] != source {
+			t.Errorf("%s comes from %s, want %s", path, loader.Sources()[path], source)
+		}
+	}
+}
+
+func TestConfigurationLoaderReportsEveryMalformedValue(t *testing.T) {
+	loader := NewConfigurationLoader()
+	environment := map[string]string{"CONFIG_PORT": "eighty", "CONFIG_LOG_LEVEL": "Nope", "CONFIG_HOST": "example.com"}
+	err := loader.ApplyEnvironment(func(key string) (string, bool) {
+		value, ok := environment[key]
+		return value, ok
+	})
+	if err == nil {
+		t.Fatal("the malformed variables are accepted")
+	}
+	for _, key := range []string{"CONFIG_PORT", "CONFIG_LOG_LEVEL"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("%s is not reported: %v", key, err)
+		}
+	}
+	if loader.Configuration().Host != "example.com" {
+		t.Error("the valid variables are not applied")
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := NewConfigurationFlags(set)
+	if err = set.Parse([]string{"--tls-reload-interval", "often"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = loader.ApplyFlags(flags); err == nil || !strings.Contains(err.Error(), "--tls-reload-interval") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestChangedConfigurationPaths(t *testing.T) {
+	previous := NewDefaultConfig()
+	current := NewDefaultConfig()
+	if changed := ChangedConfigurationPaths(previous, current); len(changed) != 0 {
+		t.Errorf("two default configurations differ by %v", changed)
+	}
+	current.Port = 9000
+	current.LogLevels = map[string]logging.LogLevel{"api": logging.Debug}
+	current.Tls.CertFile = "server.pem"
+	current.JwtSecret = "another secret"
+	expected := []string{"logLevels", "port", "jwtSecret", "tls.certFile"}
+	if changed := ChangedConfigurationPaths(previous, current); !slices.Equal(changed, expected) {
+		t.Errorf("got %v, want %v", changed, expected)
+	}
+}
+
+func TestConfigurationFileRevealsTheJwtSecret(t *testing.T) {
+	config := NewDefaultConfig()
+	config.JwtSecret = "a secret which is long enough for the validation"
+	data, err := config.MarshalFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader, err := loadTestFile(t, string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded := loader.Configuration(); loaded.JwtSecret != config.JwtSecret || !slices.Equal(ChangedConfigurationPaths(config, loaded), nil) {
+		t.Errorf("the file doesn't have the configuration: %s", data)
+	}
+	if data, _ = json.Marshal(config); strings.Contains(string(data), config.JwtSecret.Reveal()) {
+		t.Errorf("the JwtSecret is revealed by encoding/json: %s", data)
+	}
+}
+
+func TestConfigurationFileKeysAreCaseInsensitive(t *testing.T) {
+	loader, err := loadTestFile(t, `{"PORT": 9000, "Tls": {"MINVERSION": "1.3"}, "logsampling": {"WINDOW": 5}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := loader.Configuration()
+	if config.Port != 9000 || config.Tls.MinVersion != "1.3" || config.LogSampling.Window != 5 {
+		t.Errorf("got port %d, TLS %s, sampling %d", config.Port, config.Tls.MinVersion, config.LogSampling.Window)
+	}
+	for _, path := range []string{"port", "tls.minVersion", "logSampling.window"} {
+		if loader.Sources()[path] != SourceFile {
+			t.Errorf("%s comes from %s", path, loader.Sources()[path])
+		}
+	}
+	if loader.Sources()["host"] != SourceDefault {
+		t.Errorf("host comes from %s", loader.Sources()["host"])
+	}
+}
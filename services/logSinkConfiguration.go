@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"httpServer/logging"
+	"httpServer/validation"
+	"os"
+	"time"
+)
+
+// validate checks the LogSinkConfiguration, the returned map is keyed by json path relative to the LogSinkConfiguration.
+func (s *LogSinkConfiguration) validate() map[string][]*validation.ValidateError {
+	errs := make(map[string][]*validation.ValidateError)
+	switch s.Type {
+	case "stdout", "stderr", "syslog":
+	case "file":
+		if s.Path == "" {
+			errs["path"] = []*validation.ValidateError{{Reason: "Value is required for a file sink"}}
+		}
+	default:
+		errs["type"] = []*validation.ValidateError{{Reason: "Value must be one of stdout, stderr, file or syslog"}}
+	}
+	for path, value := range map[string]int{"maxSize": s.MaxSize, "rotateInterval": s.RotateInterval, "maxBackups": s.MaxBackups, "maxAge": s.MaxAge} {
+		ok, results := validation.Validate(int64(value), validation.DefaultValidateOptions,
+			validation.Integer.NotLessThan(0),
+		)
+		if !ok {
+			errs[path] = results
+		}
+	}
+	return errs
+}
+
+// validate checks the LogSamplingConfiguration, the returned map is keyed by json path relative to the LogSamplingConfiguration.
+func (s *LogSamplingConfiguration) validate() map[string][]*validation.ValidateError {
+	errs := make(map[string][]*validation.ValidateError)
+	ok, results := validation.Validate(int64(s.Window), validation.DefaultValidateOptions,
+		validation.Integer.NotLessThan(0),
+	)
+	if !ok {
+		errs["window"] = results
+	}
+	for name, limit := range s.LevelLimits {
+		_, err := logging.ParseLogLevel(name)
+		if err != nil {
+			errs["levelLimits."+name] = append(errs["levelLimits."+name], &validation.ValidateError{Reason: err.Error()})
+		}
+		ok, results := validation.Validate(int64(limit), validation.DefaultValidateOptions,
+			validation.Integer.NotLessThan(0),
+		)
+		if !ok {
+			errs["levelLimits."+name] = append(errs["levelLimits."+name], results...)
+		}
+	}
+	for category, limit := range s.CategoryLimits {
+		ok, results := validation.Validate(int64(limit), validation.DefaultValidateOptions,
+			validation.Integer.NotLessThan(0),
+		)
+		if !ok {
+			errs["categoryLimits."+category] = results
+		}
+	}
+	return errs
+}
+
+func (s *LogSamplingConfiguration) options() logging.SamplingOptions {
+	levelLimits := make(map[logging.LogLevel]int, len(s.LevelLimits))
+	for name, limit := range s.LevelLimits {
+		// the names are checked by validate
+		level, _ := logging.ParseLogLevel(name)
+		levelLimits[level] = limit
+	}
+	return logging.SamplingOptions{
+		Window:         time.Duration(s.Window) * time.Second,
+		Deduplicate:    s.Deduplicate,
+		LevelLimits:    levelLimits,
+		CategoryLimits: s.CategoryLimits,
+	}
+}
+
+// CreateLogSinks opens the LogSinks, or returns a console sink on stdout when there is none, wrapped by the LogQueue and the LogSampling.
+// The sinks already opened are closed when one of them fails.
+func (c *Configuration) CreateLogSinks() ([]logging.ISink, error) {
+	theme, err := logging.NewColorTheme(c.LogColors)
+	if err != nil {
+		return nil, err
+	}
+	var sinks []logging.ISink
+	if len(c.LogSinks) == 0 {
+		sinks = append(sinks, logging.NewWriterSink(os.Stdout, logging.Lowest, logging.NewFormatter(c.LogFormat, logging.DetectColors(os.Stdout, theme))))
+	}
+	for _, config := range c.LogSinks {
+		sink, err := config.create(c.LogFormat, theme)
+		if err != nil {
+			for _, sink := range sinks {
+				err = errors.Join(err, sink.Close())
+			}
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	for i, sink := range sinks {
+		if c.LogQueue.Size > 0 {
+			sink = logging.NewAsyncSink(sink, c.LogQueue.Size, c.LogQueue.Policy)
+		}
+		// sampling first, so the suppressed entries don't fill the queue
+		if c.LogSampling.Window > 0 {
+			sink = logging.NewSamplingSink(sink, c.LogSampling.options())
+		}
+		sinks[i] = sink
+	}
+	return sinks, nil
+}
+
+// create opens the sink, the colours are only used by the console format on terminals.
+func (s *LogSinkConfiguration) create(defaultFormat logging.LogFormat, theme logging.ColorTheme) (logging.ISink, error) {
+	format := s.Format
+	if format == "" {
+		format = defaultFormat
+	}
+	return s.open(func(theme logging.ColorTheme) logging.IFormatter {
+		return logging.NewFormatter(format, theme)
+	}, theme)
+}
+
+// open opens the sink writing the lines of newFormatter, which gets theme for the terminals and nil otherwise.
+func (s *LogSinkConfiguration) open(newFormatter func(theme logging.ColorTheme) logging.IFormatter, theme logging.ColorTheme) (logging.ISink, error) {
+	switch s.Type {
+	case "stdout":
+		return logging.NewWriterSink(os.Stdout, s.Level, newFormatter(logging.DetectColors(os.Stdout, theme))), nil
+	case "stderr":
+		return logging.NewWriterSink(os.Stderr, s.Level, newFormatter(logging.DetectColors(os.Stderr, theme))), nil
+	case "file":
+		return logging.NewRotatingFileSink(s.Path, s.Level, newFormatter(nil), logging.RotatingFileOptions{
+			MaxSize:    int64(s.MaxSize) * 1024 * 1024,
+			Interval:   time.Duration(s.RotateInterval) * time.Second,
+			MaxBackups: s.MaxBackups,
+			MaxAge:     time.Duration(s.MaxAge) * 24 * time.Hour,
+			Compress:   s.Compress,
+		})
+	default:
+		return logging.NewSyslogSink(s.Address, s.Tag, s.Level, newFormatter(nil))
+	}
+}
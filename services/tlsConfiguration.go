@@ -0,0 +1,114 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"httpServer/validation"
+	"os"
+	"strconv"
+)
+
+// validate checks the TlsConfiguration, the returned map is keyed by json path relative to the TlsConfiguration.
+func (t *TlsConfiguration) validate() map[string][]*validation.ValidateError {
+	errs := make(map[string][]*validation.ValidateError)
+	if t.CertFile != "" && t.KeyFile == "" {
+		errs["keyFile"] = []*validation.ValidateError{{Reason: "Value is required when certFile is set"}}
+	}
+	if t.CertFile == "" && t.KeyFile != "" {
+		errs["certFile"] = []*validation.ValidateError{{Reason: "Value is required when keyFile is set"}}
+	}
+	if t.CertFile == "" && len(t.RedirectListen) > 0 {
+		errs["certFile"] = append(errs["certFile"], &validation.ValidateError{Reason: "Value is required when redirectListen is set"})
+	}
+	_, err := parseTlsVersion(t.MinVersion)
+	if err != nil {
+		errs["minVersion"] = []*validation.ValidateError{{Reason: err.Error()}}
+	}
+	_, err = parseCipherSuites(t.CipherSuites)
+	if err != nil {
+		errs["cipherSuites"] = []*validation.ValidateError{{Reason: err.Error()}}
+	}
+	for i, address := range t.RedirectListen {
+		_, _, err := ParseListenAddress(address)
+		if err != nil {
+			errs["redirectListen["+strconv.Itoa(i)+"]"] = []*validation.ValidateError{{Reason: err.Error()}}
+		}
+	}
+	ok, results := validation.Validate(int64(t.ReloadInterval), validation.DefaultValidateOptions,
+		validation.Integer.NotLessThan(0),
+	)
+	if !ok {
+		errs["reloadInterval"] = results
+	}
+	return errs
+}
+
+func parseTlsVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version %q", version)
+	}
+}
+
+// parseCipherSuites converts IANA cipher suite names to their ids, nil names means the Go defaults.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// newTlsConfig builds the server side tls.Config, the certificate is served by reloader so it can change without restarting.
+func newTlsConfig(config *TlsConfiguration, reloader *certificateReloader) (*tls.Config, error) {
+	minVersion, err := parseTlsVersion(config.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := parseCipherSuites(config.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if config.ClientCaFile != "" {
+		caFile, err := os.ReadFile(config.ClientCaFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caFile) {
+			return nil, fmt.Errorf("%s contains no PEM certificate", config.ClientCaFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValidateStruct validates the fields of value, a struct or a pointer to a struct, by their `validate` tags.
+// The nested structs and the elements of the slices are validated too.
+// The errors are keyed by the name of the field in the request: its path, query, header, formData or json tag,
+// prefixed by the names of the parent fields, e.g. "items[1].name". It panics on an invalid tag.
+func ValidateStruct(value any, options *ValidateOptions) (bool, map[string][]*ValidateError) {
+	results := make(map[string][]*ValidateError)
+	validateValue(reflect.ValueOf(value), "", nil, options, results)
+	if len(results) == 0 {
+		return true, nil
+	}
+	return false, results
+}
+
+func validateValue(value reflect.Value, name string, rules *Rules, options *ValidateOptions, results map[string][]*ValidateError) {
+	var ok bool
+	var errors []*ValidateError
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			validateValue(value.Elem(), name, rules, options, results)
+		}
+		return
+	case reflect.Struct:
+		validateFields(value, name, options, results)
+		return
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateValue(value.Index(i), name+"["+strconv.Itoa(i)+"]", rules, options, results)
+		}
+		return
+	}
+	if rules == nil {
+		return
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ok, errors = Validate(value.Int(), options, rules.IntegerValidators()...)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		ok, errors = Validate(int64(value.Uint()), options, rules.IntegerValidators()...)
+	case reflect.Float32, reflect.Float64:
+		ok, errors = Validate(value.Float(), options, rules.FloatValidators()...)
+	case reflect.String:
+		ok, errors = Validate(value.String(), options, rules.StringValidators()...)
+	default:
+		return
+	}
+	if !ok {
+		results[name] = errors
+	}
+}
+
+func validateFields(value reflect.Value, prefix string, options *ValidateOptions, results map[string][]*ValidateError) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			validateFields(value.Field(i), prefix, options, results)
+			continue
+		}
+		name := FieldName(field)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		var rules *Rules
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			var err error
+			rules, err = ParseRules(tag)
+			if err != nil {
+				panic(err)
+			}
+		}
+		validateValue(value.Field(i), name, rules, options, results)
+	}
+}
+
+// FieldName returns the name of field in a request: its path, query, header, formData or json tag, or its Go name.
+func FieldName(field reflect.StructField) string {
+	for _, tag := range []string{"path", "query", "header", "formData", "json"} {
+		if name, ok := field.Tag.Lookup(tag); ok {
+			name, _, _ = strings.Cut(name, ",")
+			if name != "" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
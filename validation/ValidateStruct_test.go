@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"slices"
+	"testing"
+)
+
+type validatedItem struct {
+	Name  string  `json:"name" validate:"notEmpty,maxLength=4"`
+	Price float64 `json:"price" validate:"min=0"`
+}
+
+type AuditFields struct {
+	Author string `json:"author" validate:"pattern=^[a-z]+$"`
+}
+
+type validatedOrder struct {
+	AuditFields
+	Id       int             `path:"id" validate:"min=1"`
+	Quantity uint            `query:"quantity" validate:"max=10"`
+	Items    []validatedItem `json:"items"`
+	Codes    []string        `json:"codes" validate:"minLength=2"`
+	Shipping *validatedItem  `json:"shipping"`
+	Notes    []*validatedItem
+	Ignored  validatedItem `json:"-"`
+	private  validatedItem
+}
+
+func validOrder() *validatedOrder {
+	return &validatedOrder{
+		AuditFields: AuditFields{Author: "alice"},
+		Id:          1,
+		Quantity:    10,
+		Items:       []validatedItem{{Name: "tea", Price: 1}, {Name: "milk", Price: 0}},
+		Codes:       []string{"ab", "cde"},
+		Shipping:    &validatedItem{Name: "post", Price: 2},
+		Notes:       []*validatedItem{nil, {Name: "gift"}},
+		Ignored:     validatedItem{Name: " "},
+		private:     validatedItem{Name: " "},
+	}
+}
+
+func TestValidateStructAcceptsValidValues(t *testing.T) {
+	if ok, errors := ValidateStruct(validOrder(), DefaultValidateOptions); !ok {
+		t.Errorf("got %v", errors)
+	}
+	if ok, errors := ValidateStruct(*validOrder(), DefaultValidateOptions); !ok {
+		t.Errorf("a struct value: got %v", errors)
+	}
+}
+
+func TestValidateStructReportsNestedFields(t *testing.T) {
+	order := validOrder()
+	order.Author = "Alice"
+	order.Id = 0
+	order.Quantity = 11
+	order.Items[1] = validatedItem{Name: "chocolate", Price: -1}
+	order.Codes[1] = "c"
+	order.Shipping.Name = " "
+	order.Notes[1].Price = -2
+	ok, errors := ValidateStruct(order, DefaultValidateOptions)
+	if ok {
+		t.Fatal("the order is valid")
+	}
+	var names []string
+	for name := range errors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	expected := []string{"Notes[1].price", "author", "codes[1]", "id", "items[1].name", "items[1].price", "quantity", "shipping.name"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("got %v, want %v", names, expected)
+	}
+}
+
+func TestValidateStructPanicsOnBadTags(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("the bad tag is ignored")
+		}
+	}()
+	ValidateStruct(&struct {
+		Count int `validate:"min=many"`
+	}{}, DefaultValidateOptions)
+}
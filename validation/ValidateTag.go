@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Rules are the constraints of a `validate` struct tag, e.g. `validate:"min=1,max=4096"`:
+//   - min and max bound the integers and the floats
+//   - minLength and maxLength bound the length of the strings
+//   - notEmpty rejects the empty or whitespace strings
+//   - pattern is a regex the strings must match, it takes the rest of the tag so it must be the last rule
+//
+// The rules of a slice apply to its elements.
+type Rules struct {
+	Min       *float64
+	Max       *float64
+	MinLength *int
+	MaxLength *int
+	NotEmpty  bool
+	Pattern   *regexp.Regexp
+}
+
+var parsedRules sync.Map
+
+// ParseRules parses the value of a `validate` tag, the results are cached as there are only the tags of the source code.
+func ParseRules(tag string) (*Rules, error) {
+	if cached, ok := parsedRules.Load(tag); ok {
+		return cached.(*Rules), nil
+	}
+	rules := &Rules{}
+	rest := tag
+	for rest != "" {
+		var rule string
+		if strings.HasPrefix(rest, "pattern=") {
+			rule, rest = rest, ""
+		} else {
+			rule, rest, _ = strings.Cut(rest, ",")
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		var err error
+		switch key {
+		case "min":
+			rules.Min, err = parseRuleFloat(value)
+		case "max":
+			rules.Max, err = parseRuleFloat(value)
+		case "minLength":
+			rules.MinLength, err = parseRuleInt(value)
+		case "maxLength":
+			rules.MaxLength, err = parseRuleInt(value)
+		case "notEmpty":
+			rules.NotEmpty = true
+		case "pattern":
+			rules.Pattern, err = regexp.Compile(value)
+		default:
+			err = errors.New("unknown rule " + key)
+		}
+		if err != nil {
+			return nil, errors.New("invalid validate tag " + strconv.Quote(tag) + ": " + err.Error())
+		}
+	}
+	parsedRules.Store(tag, rules)
+	return rules, nil
+}
+
+func parseRuleFloat(value string) (*float64, error) {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func parseRuleInt(value string) (*int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// IntegerValidators returns the validators of the integer rules.
+func (r *Rules) IntegerValidators() []func(int64) *ValidateError {
+	var validators []func(int64) *ValidateError
+	switch {
+	case r.Min != nil && r.Max != nil:
+		validators = append(validators, Integer.Between(int64(*r.Min), int64(*r.Max)))
+	case r.Min != nil:
+		validators = append(validators, Integer.NotLessThan(int64(*r.Min)))
+	case r.Max != nil:
+		validators = append(validators, Integer.NotGreaterThan(int64(*r.Max)))
+	}
+	return validators
+}
+
+// FloatValidators returns the validators of the float rules.
+func (r *Rules) FloatValidators() []func(float64) *ValidateError {
+	var validators []func(float64) *ValidateError
+	switch {
+	case r.Min != nil && r.Max != nil:
+		validators = append(validators, Float.Between(*r.Min, *r.Max))
+	case r.Min != nil:
+		validators = append(validators, Float.NotLessThan(*r.Min))
+	case r.Max != nil:
+		validators = append(validators, Float.NotGreaterThan(*r.Max))
+	}
+	return validators
+}
+
+// StringValidators returns the validators of the string rules.
+func (r *Rules) StringValidators() []func(string) *ValidateError {
+	var validators []func(string) *ValidateError
+	if r.NotEmpty {
+		validators = append(validators, String.NotEmptyOrWhiteSpace())
+	}
+	if r.MinLength != nil {
+		validators = append(validators, String.NotShorterThan(*r.MinLength))
+	}
+	if r.MaxLength != nil {
+		validators = append(validators, String.NotLongerThan(*r.MaxLength))
+	}
+	if r.Pattern != nil {
+		validators = append(validators, String.MatchRegex(*r.Pattern))
+	}
+	return validators
+}
@@ -0,0 +1,44 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("min=-1.5, max=10,minLength=2,maxLength=8,notEmpty,pattern=^[a-z]{1,3}(,[a-z])?$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Min == nil || *rules.Min != -1.5 || rules.Max == nil || *rules.Max != 10 {
+		t.Errorf("got min %v, max %v", rules.Min, rules.Max)
+	}
+	if rules.MinLength == nil || *rules.MinLength != 2 || rules.MaxLength == nil || *rules.MaxLength != 8 || !rules.NotEmpty {
+		t.Errorf("got %+v", rules)
+	}
+	// the pattern takes the rest of the tag, commas included
+	if rules.Pattern == nil || rules.Pattern.String() != "^[a-z]{1,3}(,[a-z])?$" {
+		t.Errorf("got pattern %v", rules.Pattern)
+	}
+	if cached, _ := ParseRules("min=-1.5, max=10,minLength=2,maxLength=8,notEmpty,pattern=^[a-z]{1,3}(,[a-z])?$"); cached != rules {
+		t.Error("the rules of a tag are parsed twice")
+	}
+
+	rules, err = ParseRules("")
+	if err != nil || rules.Min != nil || rules.Max != nil || rules.MinLength != nil || rules.MaxLength != nil || rules.NotEmpty || rules.Pattern != nil {
+		t.Errorf("an empty tag has the rules %+v, %v", rules, err)
+	}
+}
+
+func TestParseRulesRejectsBadTags(t *testing.T) {
+	for _, tag := range []string{"min=one", "max=", "minLength=1.5", "maxLength=x", "between=1", "min", "pattern=[a-z", "pattern=(", "min=1;max=2"} {
+		rules, err := ParseRules(tag)
+		if err == nil {
+			t.Errorf("%q is parsed to %+v", tag, rules)
+			continue
+		}
+		if !strings.Contains(err.Error(), tag) {
+			t.Errorf("%q: the error doesn't quote the tag: %v", tag, err)
+		}
+	}
+}